@@ -0,0 +1,21 @@
+package echoadapter
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/mrwangjinjin/go-wechat/core"
+)
+
+// Handler 同一地址接收授权事件与消息推送
+func Handler(server *core.Server) echo.HandlerFunc {
+	return echo.WrapHandler(server)
+}
+
+// Notify 授权事件接收地址
+func Notify(server *core.Server) echo.HandlerFunc {
+	return echo.WrapHandler(server.HandleNotify())
+}
+
+// Event 消息与事件接收地址
+func Event(server *core.Server) echo.HandlerFunc {
+	return echo.WrapHandler(server.HandleEvent())
+}
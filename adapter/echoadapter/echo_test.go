@@ -0,0 +1,84 @@
+package echoadapter
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/mrwangjinjin/go-wechat/core"
+	"github.com/mrwangjinjin/go-wechat/internal/util"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	ticketPush = "<xml><AppId><![CDATA[wx_component]]></AppId><CreateTime>1600000000</CreateTime>" +
+		"<InfoType><![CDATA[component_verify_ticket]]></InfoType><ComponentVerifyTicket><![CDATA[ticket@@@1]]></ComponentVerifyTicket></xml>"
+	textPush = "<xml><ToUserName><![CDATA[gh_1]]></ToUserName><FromUserName><![CDATA[o_user]]></FromUserName>" +
+		"<CreateTime>1600000000</CreateTime><MsgType><![CDATA[text]]></MsgType><Content><![CDATA[ping]]></Content><MsgId>1</MsgId></xml>"
+)
+
+// newPush 构造明文模式的推送, body 为空时为 echostr 校验请求
+func newPush(path, nonce, signature, body string) *http.Request {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	if signature == "" {
+		signature = util.Sign("token", timestamp, nonce)
+	}
+	query := url.Values{
+		"timestamp": {timestamp},
+		"nonce":     {nonce},
+		"signature": {signature},
+	}
+	if body == "" {
+		query.Set("echostr", "echo")
+		return httptest.NewRequest(http.MethodGet, path+"?"+query.Encode(), nil)
+	}
+	return httptest.NewRequest(http.MethodPost, path+"?"+query.Encode(), strings.NewReader(body))
+}
+
+func TestAdapter(t *testing.T) {
+	tests := []struct {
+		name    string
+		request *http.Request
+		status  int
+		body    string
+		ticket  string
+	}{
+		{name: "Handler echostr", request: newPush("/wechat", "1", "", ""), status: http.StatusOK, body: "echo"},
+		{name: "Handler bad signature", request: newPush("/wechat", "2", "bad", textPush), status: http.StatusForbidden},
+		{name: "Handler notify", request: newPush("/wechat", "3", "", ticketPush), status: http.StatusOK, body: "success", ticket: "ticket@@@1"},
+		{name: "Handler event", request: newPush("/wechat", "4", "", textPush), status: http.StatusOK, body: "<Content><![CDATA[pong]]></Content>"},
+		{name: "Notify", request: newPush("/notify", "5", "", ticketPush), status: http.StatusOK, body: "success", ticket: "ticket@@@1"},
+		{name: "Event", request: newPush("/event", "6", "", textPush), status: http.StatusOK, body: "<Content><![CDATA[pong]]></Content>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := core.NewServer(&core.ClientConfig{AppId: "wx_component", Token: "token"}, core.NewMemoryCache())
+			var ticket string
+			server.OnTicket(func(event *core.TicketEvent) {
+				ticket = event.ComponentVerifyTicket
+			})
+			server.OnEvent(func(message *core.EventMessage) core.Reply {
+				return core.NewTextReply("pong")
+			})
+			router := echo.New()
+			router.Any("/wechat", Handler(server))
+			router.POST("/notify", Notify(server))
+			router.POST("/event", Event(server))
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, tt.request)
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.body) {
+				t.Fatalf("expected %q in %q", tt.body, w.Body.String())
+			}
+			if ticket != tt.ticket {
+				t.Fatalf("expected ticket %q, got %q", tt.ticket, ticket)
+			}
+		})
+	}
+}
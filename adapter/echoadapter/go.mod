@@ -0,0 +1,10 @@
+module github.com/mrwangjinjin/go-wechat/adapter/echoadapter
+
+go 1.13
+
+require (
+	github.com/labstack/echo/v4 v4.1.17
+	github.com/mrwangjinjin/go-wechat v0.1.0
+)
+
+replace github.com/mrwangjinjin/go-wechat => ../..
@@ -0,0 +1,22 @@
+package fasthttpadapter
+
+import (
+	"github.com/mrwangjinjin/go-wechat/core"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// Handler 同一地址接收授权事件与消息推送
+func Handler(server *core.Server) fasthttp.RequestHandler {
+	return fasthttpadaptor.NewFastHTTPHandler(server)
+}
+
+// Notify 授权事件接收地址
+func Notify(server *core.Server) fasthttp.RequestHandler {
+	return fasthttpadaptor.NewFastHTTPHandler(server.HandleNotify())
+}
+
+// Event 消息与事件接收地址
+func Event(server *core.Server) fasthttp.RequestHandler {
+	return fasthttpadaptor.NewFastHTTPHandler(server.HandleEvent())
+}
@@ -0,0 +1,10 @@
+module github.com/mrwangjinjin/go-wechat/adapter/fasthttpadapter
+
+go 1.13
+
+require (
+	github.com/mrwangjinjin/go-wechat v0.1.0
+	github.com/valyala/fasthttp v1.16.0
+)
+
+replace github.com/mrwangjinjin/go-wechat => ../..
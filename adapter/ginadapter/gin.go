@@ -0,0 +1,21 @@
+package ginadapter
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mrwangjinjin/go-wechat/core"
+)
+
+// Handler 同一地址接收授权事件与消息推送
+func Handler(server *core.Server) gin.HandlerFunc {
+	return gin.WrapH(server)
+}
+
+// Notify 授权事件接收地址
+func Notify(server *core.Server) gin.HandlerFunc {
+	return gin.WrapH(server.HandleNotify())
+}
+
+// Event 消息与事件接收地址
+func Event(server *core.Server) gin.HandlerFunc {
+	return gin.WrapH(server.HandleEvent())
+}
@@ -0,0 +1,10 @@
+module github.com/mrwangjinjin/go-wechat/adapter/ginadapter
+
+go 1.13
+
+require (
+	github.com/gin-gonic/gin v1.6.3
+	github.com/mrwangjinjin/go-wechat v0.1.0
+)
+
+replace github.com/mrwangjinjin/go-wechat => ../..
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync"
+)
+
+const (
+	DefaultAsyncWorkers   = 8
+	DefaultAsyncQueueSize = 1024
+)
+
+var (
+	ErrQueueFull    = errors.New("推送处理队列已满")
+	ErrHandlerPanic = errors.New("推送处理函数异常")
+)
+
+// asyncPool 异步模式下处理推送的协程池
+type asyncPool struct {
+	mu     sync.RWMutex
+	closed bool
+	jobs   chan func()
+	wg     sync.WaitGroup
+}
+
+// StartAsync 开启异步模式, 推送解密后立即回复 success, 处理函数在 workers 个协程中执行,
+// 队列已满时返回503由微信重试. 异步模式下 EventReplyServe 的返回值不会作为被动回复
+func (self *Server) StartAsync(workers, queueSize int) {
+	if workers <= 0 {
+		workers = DefaultAsyncWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultAsyncQueueSize
+	}
+	pool := &asyncPool{
+		jobs: make(chan func(), queueSize),
+	}
+	pool.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer pool.wg.Done()
+			for job := range pool.jobs {
+				job()
+			}
+		}()
+	}
+	self.async = pool
+}
+
+// Close 停止接收新的推送, 等待队列中的推送处理完成
+func (self *Server) Close() {
+	pool := self.async
+	if pool == nil {
+		return
+	}
+	pool.mu.Lock()
+	if !pool.closed {
+		pool.closed = true
+		close(pool.jobs)
+	}
+	pool.mu.Unlock()
+	pool.wg.Wait()
+}
+
+// enqueue 放入处理队列, 队列已满或已关闭时返回 false
+func (self *asyncPool) enqueue(job func()) bool {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	if self.closed {
+		return false
+	}
+	select {
+	case self.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// dispatchAsync 放入处理队列并回复 success, 队列已满时返回503与 false
+func (self *Server) dispatchAsync(w http.ResponseWriter, r *http.Request, job func()) bool {
+	ok := self.async.enqueue(func() {
+		defer func() {
+			if e := recover(); e != nil {
+				self.reportPanic(r, e)
+			}
+		}()
+		job()
+	})
+	if !ok {
+		self.fail(w, r, &ServeError{Kind: ErrQueueFull, Status: http.StatusServiceUnavailable})
+		return false
+	}
+	writeSuccess(w)
+	return true
+}
+
+// reportPanic 异步处理函数 panic 时通过 OnError 注册的函数通知
+func (self *Server) reportPanic(r *http.Request, e interface{}) {
+	err := panicError(e)
+	log.Println(err)
+	for _, handler := range self.errorHandlers {
+		handler(r, err)
+	}
+}
+
+// panicError 包装处理函数的 panic 与调用栈
+func panicError(e interface{}) *ServeError {
+	return &ServeError{
+		Kind:   ErrHandlerPanic,
+		Status: http.StatusInternalServerError,
+		Err:    fmt.Errorf("%v\n%s", e, debug.Stack()),
+	}
+}
+
+// callSafely 同步调用处理函数, panic 时返回 ErrHandlerPanic
+func callSafely(fn func()) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = panicError(e)
+		}
+	}()
+	fn()
+	return nil
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// textPush 不同 MsgId 的文本消息
+func textPush(msgId int) string {
+	return "<xml><ToUserName><![CDATA[gh_1]]></ToUserName><FromUserName><![CDATA[o_user]]></FromUserName>" +
+		"<CreateTime>1600000000</CreateTime><MsgType><![CDATA[text]]></MsgType><Content><![CDATA[hi]]></Content>" +
+		"<MsgId>" + strconv.Itoa(msgId) + "</MsgId></xml>"
+}
+
+func TestAsyncQueueFull(t *testing.T) {
+	server := newTestServer()
+	server.StartAsync(1, 1)
+	var mu sync.Mutex
+	var errs []error
+	server.OnError(func(r *http.Request, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	})
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	handled := map[string]int{}
+	handler := func(message *EventMessage) {
+		started <- struct{}{}
+		<-release
+		mu.Lock()
+		defer mu.Unlock()
+		handled[strconv.FormatInt(message.MsgId, 10)]++
+	}
+
+	tests := []struct {
+		name   string
+		nonce  string
+		msgId  int
+		status int
+	}{
+		{"taken by the worker", "1", 3001, http.StatusOK},
+		{"queued", "2", 3002, http.StatusOK},
+		{"queue full", "3", 3003, http.StatusServiceUnavailable},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		server.EventServe(w, newPlainPushWithNonce(server, tt.nonce, textPush(tt.msgId)), handler)
+		if w.Code != tt.status {
+			t.Fatalf("%s: expected %d, got %d", tt.name, tt.status, w.Code)
+		}
+		if i == 0 {
+			// 等待 worker 取走第一个推送, 队列只剩一个位置
+			<-started
+		}
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", errs)
+	}
+
+	// 队列已满的推送未被记录, 微信以相同的 nonce 重试时可以处理
+	close(release)
+	<-started
+	w := httptest.NewRecorder()
+	server.EventServe(w, newPlainPushWithNonce(server, "3", textPush(3003)), handler)
+	if w.Code != http.StatusOK {
+		t.Fatalf("retry after queue full: expected 200, got %d", w.Code)
+	}
+	server.Close()
+	for _, msgId := range []string{"3001", "3002", "3003"} {
+		if handled[msgId] != 1 {
+			t.Fatalf("message %s handled %d times", msgId, handled[msgId])
+		}
+	}
+
+	// 关闭后的推送返回503由微信重试
+	w = httptest.NewRecorder()
+	server.EventServe(w, newPlainPushWithNonce(server, "4", textPush(3004)), handler)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("after Close: expected 503, got %d", w.Code)
+	}
+}
+
+func TestAsyncHandlerPanicIsReported(t *testing.T) {
+	server := newTestServer()
+	server.StartAsync(1, 1)
+	reported := make(chan error, 1)
+	server.OnError(func(r *http.Request, err error) {
+		reported <- err
+	})
+	w := httptest.NewRecorder()
+	server.EventServe(w, newPlainPush(server, textPush(3101)), func(message *EventMessage) {
+		panic("boom")
+	})
+	server.Close()
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	select {
+	case err := <-reported:
+		if !errors.Is(err, ErrHandlerPanic) {
+			t.Fatalf("expected ErrHandlerPanic, got %v", err)
+		}
+	default:
+		t.Fatal("panic was not reported")
+	}
+}
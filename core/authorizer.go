@@ -0,0 +1,76 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const (
+	AuthorizerUserNameCacheKeyPrefix = "CACHE_AUTHORIZER_USERNAME@@"
+)
+
+// OnAuthorizerEvent 指定授权方的消息与事件推送, 授权方未注册处理函数时使用 OnEvent 注册的处理函数
+func (self *Server) OnAuthorizerEvent(authorizerAppId string, handler EventReplyHandler) {
+	if self.notify.authorizerEvent == nil {
+		self.notify.authorizerEvent = make(map[string][]EventReplyHandler)
+	}
+	self.notify.authorizerEvent[authorizerAppId] = append(self.notify.authorizerEvent[authorizerAppId], handler)
+}
+
+// MapAuthorizer 登记授权方原始ID(gh_开头, 即消息的 ToUserName)与 appid 的对应关系
+func (self *Server) MapAuthorizer(userName, authorizerAppId string) {
+	self.authorizerMu.Lock()
+	defer self.authorizerMu.Unlock()
+	if self.authorizerNames == nil {
+		self.authorizerNames = make(map[string]string)
+	}
+	self.authorizerNames[userName] = authorizerAppId
+}
+
+// resolveAuthorizer 依次从 AuthorizerRoute、MapAuthorizer 登记的对应关系与缓存中查找授权方 appid
+func (self *Server) resolveAuthorizer(r *http.Request, message *EventMessage) string {
+	if self.AuthorizerRoute != nil {
+		if appId := self.AuthorizerRoute(r); appId != "" {
+			return appId
+		}
+	}
+	self.authorizerMu.RLock()
+	appId, ok := self.authorizerNames[message.ToUserName]
+	self.authorizerMu.RUnlock()
+	if ok {
+		return appId
+	}
+	if self.Cache == nil || message.ToUserName == "" {
+		return ""
+	}
+	// 由 open.AuthorizationLifecycle 在授权时写入
+	val, err := self.Cache.Get(AuthorizerUserNameCacheKeyPrefix + message.ToUserName)
+	if err != nil {
+		return ""
+	}
+	_ = json.Unmarshal([]byte(val), &appId)
+	return appId
+}
+
+// PathTemplateRoute 按路径模板取授权方 appid, 如 PathTemplateRoute("/event/{appid}")
+func PathTemplateRoute(template string) func(r *http.Request) string {
+	parts := strings.Split(strings.Trim(template, "/"), "/")
+	return func(r *http.Request) string {
+		segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(segments) != len(parts) {
+			return ""
+		}
+		appId := ""
+		for i, part := range parts {
+			if part == "{appid}" {
+				appId = segments[i]
+				continue
+			}
+			if part != segments[i] {
+				return ""
+			}
+		}
+		return appId
+	}
+}
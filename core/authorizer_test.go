@@ -0,0 +1,101 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPathTemplateRoute(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		path     string
+		appId    string
+	}{
+		{"match", "/event/{appid}", "/event/wx_a", "wx_a"},
+		{"trailing slash", "/event/{appid}", "/event/wx_a/", "wx_a"},
+		{"template without leading slash", "event/{appid}", "/event/wx_a", "wx_a"},
+		{"middle segment", "/wechat/{appid}/callback", "/wechat/wx_a/callback", "wx_a"},
+		{"prefix mismatch", "/event/{appid}", "/notify/wx_a", ""},
+		{"suffix mismatch", "/wechat/{appid}/callback", "/wechat/wx_a/notify", ""},
+		{"too short", "/event/{appid}", "/event", ""},
+		{"too long", "/event/{appid}", "/event/wx_a/extra", ""},
+		{"no placeholder", "/event", "/event", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, tt.path+"?timestamp=1", nil)
+			if got := PathTemplateRoute(tt.template)(r); got != tt.appId {
+				t.Fatalf("expected %q, got %q", tt.appId, got)
+			}
+		})
+	}
+}
+
+func TestResolveAuthorizer(t *testing.T) {
+	tests := []struct {
+		name       string
+		route      func(r *http.Request) string
+		mapped     map[string]string // MapAuthorizer 登记的对应关系
+		cached     map[string]string // 授权时写入缓存的对应关系
+		toUserName string
+		appId      string
+	}{
+		{"route", PathTemplateRoute("/event/{appid}"), map[string]string{"gh_1": "wx_mapped"}, nil, "gh_1", "wx_route"},
+		{"route without match falls back", PathTemplateRoute("/other/{appid}"), map[string]string{"gh_1": "wx_mapped"}, nil, "gh_1", "wx_mapped"},
+		{"mapped before cache", nil, map[string]string{"gh_1": "wx_mapped"}, map[string]string{"gh_1": "wx_cached"}, "gh_1", "wx_mapped"},
+		{"cache", nil, nil, map[string]string{"gh_1": "wx_cached"}, "gh_1", "wx_cached"},
+		{"unknown", nil, map[string]string{"gh_2": "wx_mapped"}, map[string]string{"gh_2": "wx_cached"}, "gh_1", ""},
+		{"empty ToUserName", nil, nil, map[string]string{"": "wx_cached"}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer()
+			server.AuthorizerRoute = tt.route
+			for userName, appId := range tt.mapped {
+				server.MapAuthorizer(userName, appId)
+			}
+			for userName, appId := range tt.cached {
+				_ = server.Cache.Set(AuthorizerUserNameCacheKeyPrefix+userName, appId)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/event/wx_route", nil)
+			message := &EventMessage{EventHeaderMessage: EventHeaderMessage{ToUserName: tt.toUserName}}
+			if got := server.resolveAuthorizer(r, message); got != tt.appId {
+				t.Fatalf("expected %q, got %q", tt.appId, got)
+			}
+		})
+	}
+}
+
+func TestAuthorizerEventHandlers(t *testing.T) {
+	server := newTestServer()
+	server.MapAuthorizer("gh_1", "wx_a")
+	var got []string
+	server.OnAuthorizerEvent("wx_a", func(message *EventMessage) Reply {
+		got = append(got, "wx_a:"+message.AuthorizerAppId)
+		return nil
+	})
+	server.OnEvent(func(message *EventMessage) Reply {
+		got = append(got, "fallback:"+message.AuthorizerAppId)
+		return nil
+	})
+	tests := []struct {
+		nonce      string
+		toUserName string
+		want       string
+	}{
+		{"1", "gh_1", "wx_a:wx_a"},
+		{"2", "gh_2", "fallback:"},
+	}
+	for _, tt := range tests {
+		body := "<xml><ToUserName><![CDATA[" + tt.toUserName + "]]></ToUserName><FromUserName><![CDATA[o_user]]></FromUserName>" +
+			"<CreateTime>1600000000</CreateTime><MsgType><![CDATA[text]]></MsgType><Content><![CDATA[hi]]></Content><MsgId>" + tt.nonce + "</MsgId></xml>"
+		got = nil
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, newPlainPushWithNonce(server, tt.nonce, body))
+		if w.Code != http.StatusOK || len(got) != 1 || got[0] != tt.want {
+			t.Fatalf("%s: status=%d handled=%v, expected %s", tt.toUserName, w.Code, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"strings"
+)
+
+// 全网发布检测使用的测试帐号原始ID
+const (
+	AutoTestUserName   = "gh_8dad206e9538"
+	AutoTestMpUserName = "gh_3c884a361561"
+)
+
+const (
+	AutoTestTextContent     = "TESTCOMPONENT_MSG_TYPE_TEXT"
+	AutoTestQueryAuthPrefix = "QUERY_AUTH_CODE:"
+)
+
+// AutoTestQueryAuthHandler 全网发布检测的授权码, 需换取令牌后以客服消息回复 authCode+"_from_api",
+// 由 open.AutoTest 实现
+type AutoTestQueryAuthHandler func(authCode string, message *EventMessage)
+
+// OnAutoTestQueryAuth 全网发布检测的 QUERY_AUTH_CODE 消息, 在新的协程中调用
+func (self *Server) OnAutoTestQueryAuth(handler AutoTestQueryAuthHandler) {
+	self.notify.autoTestQueryAuth = append(self.notify.autoTestQueryAuth, handler)
+}
+
+// isAutoTest 是否为全网发布检测帐号的推送
+func isAutoTest(message *EventMessage) bool {
+	switch message.AuthorizerAppId {
+	case AutoTestAppId, AutoTestMpId:
+		return true
+	}
+	switch message.ToUserName {
+	case AutoTestUserName, AutoTestMpUserName:
+		return true
+	}
+	return false
+}
+
+// autoTest 响应全网发布检测, 不是检测推送时 handled 为 false;
+// 文本消息回复 TESTCOMPONENT_MSG_TYPE_TEXT_callback, 事件回复 <Event>from_callback,
+// QUERY_AUTH_CODE 回复空串并异步调用 OnAutoTestQueryAuth 注册的处理函数
+func (self *Server) autoTest(message *EventMessage) (reply Reply, handled bool) {
+	if !isAutoTest(message) {
+		return nil, false
+	}
+	switch message.MsgType {
+	case MsgTypeText:
+		if message.Content == AutoTestTextContent {
+			return NewTextReply(AutoTestTextContent + "_callback"), true
+		}
+		if strings.HasPrefix(message.Content, AutoTestQueryAuthPrefix) {
+			authCode := strings.TrimPrefix(message.Content, AutoTestQueryAuthPrefix)
+			for _, handler := range self.notify.autoTestQueryAuth {
+				go handler(authCode, message)
+			}
+			return nil, true
+		}
+	case MsgTypeEvent:
+		return NewTextReply(message.Event + "from_callback"), true
+	}
+	return nil, false
+}
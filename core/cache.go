@@ -2,8 +2,11 @@ package core
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gomodule/redigo/redis"
 	"github.com/mrwangjinjin/go-wechat/pkg/util"
+	"net"
 	"time"
 )
 
@@ -13,8 +16,24 @@ type Cache interface {
 	SetEx(key string, val interface{}, expires int64) error
 	Get(key string) (string, error)
 	Exists(key string) bool
+	// SetNX 仅在key不存在时写入, 返回是否写入成功
+	SetNX(key string, val interface{}, expires int64) (bool, error)
+	Delete(key string) error
+	// DeleteIfEquals 仅在key的值等于 val 时删除, 返回是否删除, 用于释放自己持有的锁
+	DeleteIfEquals(key string, val interface{}) (bool, error)
+	// TTL 剩余有效秒数, key不存在时返回-2, 未设置过期时间时返回-1
+	TTL(key string) (int64, error)
+	// SAdd 向集合添加成员, 多个实例同时添加时不会互相覆盖
+	SAdd(key string, member string) error
+	// SRem 从集合移除成员, 集合为空时删除key
+	SRem(key string, member string) error
+	// SMembers 集合的所有成员, key不存在时返回空
+	SMembers(key string) ([]string, error)
 }
 
+// ErrCacheMiss 缓存不存在
+var ErrCacheMiss = errors.New("缓存不存在")
+
 // CacheConfig
 type CacheConfig struct {
 	MaxIdle     int
@@ -22,51 +41,118 @@ type CacheConfig struct {
 	IdleTimeout time.Duration
 	Host        string
 	Auth        string
+	// SentinelAddrs 哨兵地址, 设置后通过哨兵获取 MasterName 对应的主节点, 忽略 Host
+	SentinelAddrs []string
+	MasterName    string
+	SentinelAuth  string
+	// ClusterAddrs 集群种子节点地址, 用于 NewClusterCache
+	ClusterAddrs []string
 }
 
 type CacheDefault struct {
 	redis *redis.Pool
 }
 
+// NewCache 单节点或哨兵模式的Redis缓存
 func NewCache(config *CacheConfig) *CacheDefault {
-	return &CacheDefault{
-		redis: &redis.Pool{
-			MaxIdle:     config.MaxIdle,
-			MaxActive:   config.MaxActive,
-			IdleTimeout: config.IdleTimeout,
-			Dial: func() (redis.Conn, error) {
-				c, err := redis.Dial("tcp", config.Host)
-				if err != nil {
-					return nil, err
-				}
-				if config.Auth != "" {
-					if _, err := c.Do("AUTH", config.Auth); err != nil {
-						_ = c.Close()
-						return nil, err
-					}
-				}
-				return c, err
-			},
-			TestOnBorrow: func(c redis.Conn, t time.Time) error {
-				_, err := c.Do("PING")
+	if len(config.SentinelAddrs) > 0 {
+		pool := newRedisPool(config, func() (redis.Conn, error) {
+			return dialSentinelMaster(config)
+		})
+		// 主从切换后旧主节点的连接不再可用
+		pool.TestOnBorrow = func(c redis.Conn, t time.Time) error {
+			role, err := redis.Values(c.Do("ROLE"))
+			if err != nil {
 				return err
-			},
+			}
+			if len(role) == 0 || fmt.Sprintf("%s", role[0]) != "master" {
+				return errors.New("redis: 节点已不是主节点")
+			}
+			return nil
+		}
+		return &CacheDefault{
+			redis: pool,
+		}
+	}
+	return &CacheDefault{
+		redis: newRedisPool(config, func() (redis.Conn, error) {
+			return dialRedis(config.Host, config.Auth)
+		}),
+	}
+}
+
+func newRedisPool(config *CacheConfig, dial func() (redis.Conn, error)) *redis.Pool {
+	return &redis.Pool{
+		MaxIdle:     config.MaxIdle,
+		MaxActive:   config.MaxActive,
+		IdleTimeout: config.IdleTimeout,
+		Dial:        dial,
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
 		},
 	}
 }
 
+func dialRedis(addr, auth string) (redis.Conn, error) {
+	c, err := redis.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if auth != "" {
+		if _, err := c.Do("AUTH", auth); err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+	}
+	return c, err
+}
+
+// dialSentinelMaster 依次询问哨兵获取主节点地址
+func dialSentinelMaster(config *CacheConfig) (redis.Conn, error) {
+	err := errors.New("redis: 没有可用的哨兵")
+	for _, addr := range config.SentinelAddrs {
+		sentinel, dialErr := dialRedis(addr, config.SentinelAuth)
+		if dialErr != nil {
+			err = dialErr
+			continue
+		}
+		master, replyErr := redis.Strings(sentinel.Do("SENTINEL", "get-master-addr-by-name", config.MasterName))
+		_ = sentinel.Close()
+		if replyErr != nil {
+			err = replyErr
+			continue
+		}
+		if len(master) != 2 {
+			err = fmt.Errorf("redis: 哨兵返回的主节点地址无效: %v", master)
+			continue
+		}
+		return dialRedis(net.JoinHostPort(master[0], master[1]), config.Auth)
+	}
+	return nil, err
+}
+
+// encodeCacheValue Redis中的缓存值为base64编码的JSON
+func encodeCacheValue(val interface{}) (string, error) {
+	value, err := json.Marshal(val)
+	if err != nil {
+		return "", err
+	}
+	return util.Base64Encoding(value), nil
+}
+
 func (self *CacheDefault) Set(key string, val interface{}) error {
 	conn := self.redis.Get()
 	defer func() {
 		_ = conn.Close()
 	}()
 
-	value, err := json.Marshal(val)
+	value, err := encodeCacheValue(val)
 	if err != nil {
 		return err
 	}
 
-	_, err = conn.Do("SET", key, util.Base64Encoding(value))
+	_, err = conn.Do("SET", key, value)
 	if err != nil {
 		return err
 	}
@@ -80,17 +166,17 @@ func (self *CacheDefault) SetEx(key string, val interface{}, expires int64) erro
 		_ = conn.Close()
 	}()
 
-	value, err := json.Marshal(val)
+	value, err := encodeCacheValue(val)
 	if err != nil {
 		return err
 	}
 
-	_, err = conn.Do("SET", key, util.Base64Encoding(value))
-	if err != nil {
+	if expires <= 0 {
+		_, err = conn.Do("SET", key, value)
 		return err
 	}
 
-	_, err = conn.Do("EXPIRE", key, expires)
+	_, err = conn.Do("SET", key, value, "EX", expires)
 	if err != nil {
 		return err
 	}
@@ -98,6 +184,28 @@ func (self *CacheDefault) SetEx(key string, val interface{}, expires int64) erro
 	return nil
 }
 
+func (self *CacheDefault) SetNX(key string, val interface{}, expires int64) (bool, error) {
+	conn := self.redis.Get()
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	value, err := encodeCacheValue(val)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = redis.String(conn.Do("SET", key, value, "EX", expires, "NX"))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
 func (self *CacheDefault) Get(key string) (reply string, err error) {
 	conn := self.redis.Get()
 	defer func() {
@@ -105,6 +213,9 @@ func (self *CacheDefault) Get(key string) (reply string, err error) {
 	}()
 
 	result, err := redis.Bytes(conn.Do("GET", key))
+	if err == redis.ErrNil {
+		return "", ErrCacheMiss
+	}
 	if err != nil {
 		return "", err
 	}
@@ -125,3 +236,75 @@ func (self *CacheDefault) Exists(key string) bool {
 
 	return exists
 }
+
+func (self *CacheDefault) Delete(key string) error {
+	conn := self.redis.Get()
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	_, err := conn.Do("DEL", key)
+	return err
+}
+
+// deleteIfEqualsSource 比较与删除在Redis中原子执行
+const deleteIfEqualsSource = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+
+var deleteIfEqualsScript = redis.NewScript(1, deleteIfEqualsSource)
+
+func (self *CacheDefault) DeleteIfEquals(key string, val interface{}) (bool, error) {
+	conn := self.redis.Get()
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	value, err := encodeCacheValue(val)
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := redis.Int(deleteIfEqualsScript.Do(conn, key, value))
+	if err != nil {
+		return false, err
+	}
+
+	return deleted > 0, nil
+}
+
+func (self *CacheDefault) TTL(key string) (int64, error) {
+	conn := self.redis.Get()
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	return redis.Int64(conn.Do("TTL", key))
+}
+
+func (self *CacheDefault) SAdd(key string, member string) error {
+	conn := self.redis.Get()
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	_, err := conn.Do("SADD", key, member)
+	return err
+}
+
+func (self *CacheDefault) SRem(key string, member string) error {
+	conn := self.redis.Get()
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	_, err := conn.Do("SREM", key, member)
+	return err
+}
+
+func (self *CacheDefault) SMembers(key string) ([]string, error) {
+	conn := self.redis.Get()
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	return redis.Strings(conn.Do("SMEMBERS", key))
+}
@@ -0,0 +1,279 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"github.com/mrwangjinjin/go-wechat/pkg/util"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	clusterSlots       = 16384
+	clusterMaxRedirect = 3
+)
+
+// CacheCluster Redis集群缓存, 按key的哈希槽将命令发送到对应的主节点
+type CacheCluster struct {
+	config *CacheConfig
+
+	mu    sync.RWMutex
+	slots [clusterSlots]string
+	pools map[string]*redis.Pool
+}
+
+func NewClusterCache(config *CacheConfig) *CacheCluster {
+	return &CacheCluster{
+		config: config,
+		pools:  make(map[string]*redis.Pool),
+	}
+}
+
+func (self *CacheCluster) Set(key string, val interface{}) error {
+	value, err := encodeCacheValue(val)
+	if err != nil {
+		return err
+	}
+	_, err = self.do(key, "SET", key, value)
+	return err
+}
+
+func (self *CacheCluster) SetEx(key string, val interface{}, expires int64) error {
+	value, err := encodeCacheValue(val)
+	if err != nil {
+		return err
+	}
+	if expires <= 0 {
+		_, err = self.do(key, "SET", key, value)
+		return err
+	}
+	_, err = self.do(key, "SET", key, value, "EX", expires)
+	return err
+}
+
+func (self *CacheCluster) SetNX(key string, val interface{}, expires int64) (bool, error) {
+	value, err := encodeCacheValue(val)
+	if err != nil {
+		return false, err
+	}
+	_, err = redis.String(self.do(key, "SET", key, value, "EX", expires, "NX"))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (self *CacheCluster) Get(key string) (string, error) {
+	result, err := redis.Bytes(self.do(key, "GET", key))
+	if err == redis.ErrNil {
+		return "", ErrCacheMiss
+	}
+	if err != nil {
+		return "", err
+	}
+	return util.Base64Decoding(result)
+}
+
+func (self *CacheCluster) Exists(key string) bool {
+	exists, err := redis.Bool(self.do(key, "EXISTS", key))
+	if err != nil {
+		return false
+	}
+	return exists
+}
+
+func (self *CacheCluster) Delete(key string) error {
+	_, err := self.do(key, "DEL", key)
+	return err
+}
+
+func (self *CacheCluster) DeleteIfEquals(key string, val interface{}) (bool, error) {
+	value, err := encodeCacheValue(val)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := redis.Int(self.do(key, "EVAL", deleteIfEqualsSource, 1, key, value))
+	if err != nil {
+		return false, err
+	}
+	return deleted > 0, nil
+}
+
+func (self *CacheCluster) TTL(key string) (int64, error) {
+	return redis.Int64(self.do(key, "TTL", key))
+}
+
+func (self *CacheCluster) SAdd(key string, member string) error {
+	_, err := self.do(key, "SADD", key, member)
+	return err
+}
+
+func (self *CacheCluster) SRem(key string, member string) error {
+	_, err := self.do(key, "SREM", key, member)
+	return err
+}
+
+func (self *CacheCluster) SMembers(key string) ([]string, error) {
+	return redis.Strings(self.do(key, "SMEMBERS", key))
+}
+
+// do 发送命令到key所在节点, 处理 MOVED/ASK 重定向
+func (self *CacheCluster) do(key string, cmd string, args ...interface{}) (interface{}, error) {
+	addr, err := self.nodeAddr(key)
+	if err != nil {
+		return nil, err
+	}
+	asking := false
+	for i := 0; ; i++ {
+		conn := self.pool(addr).Get()
+		if asking {
+			_, _ = conn.Do("ASKING")
+		}
+		reply, err := conn.Do(cmd, args...)
+		_ = conn.Close()
+
+		redirect, ok := err.(redis.Error)
+		if !ok || i >= clusterMaxRedirect {
+			return reply, err
+		}
+		fields := strings.Fields(string(redirect))
+		if len(fields) != 3 {
+			return reply, err
+		}
+		switch fields[0] {
+		case "MOVED":
+			// 槽位已迁移, 重新加载槽位映射
+			_ = self.refresh()
+			addr, asking = fields[2], false
+		case "ASK":
+			addr, asking = fields[2], true
+		default:
+			return reply, err
+		}
+	}
+}
+
+// nodeAddr 获取key所在的节点地址, 首次使用时加载槽位映射
+func (self *CacheCluster) nodeAddr(key string) (string, error) {
+	slot := clusterSlot(key)
+	self.mu.RLock()
+	addr := self.slots[slot]
+	self.mu.RUnlock()
+	if addr != "" {
+		return addr, nil
+	}
+	err := self.refresh()
+	if err != nil {
+		return "", err
+	}
+	self.mu.RLock()
+	addr = self.slots[slot]
+	self.mu.RUnlock()
+	if addr == "" {
+		return "", fmt.Errorf("redis: 槽位 %d 没有可用节点", slot)
+	}
+	return addr, nil
+}
+
+// refresh 通过 CLUSTER SLOTS 加载槽位与主节点的映射
+func (self *CacheCluster) refresh() error {
+	err := errors.New("redis: 没有可用的集群节点")
+	for _, seed := range self.seeds() {
+		conn := self.pool(seed).Get()
+		ranges, replyErr := redis.Values(conn.Do("CLUSTER", "SLOTS"))
+		_ = conn.Close()
+		if replyErr != nil {
+			err = replyErr
+			continue
+		}
+
+		var slots [clusterSlots]string
+		for _, r := range ranges {
+			slotRange, rangeErr := redis.Values(r, nil)
+			if rangeErr != nil || len(slotRange) < 3 {
+				continue
+			}
+			start, _ := redis.Int(slotRange[0], nil)
+			end, _ := redis.Int(slotRange[1], nil)
+			master, masterErr := redis.Values(slotRange[2], nil)
+			if masterErr != nil || len(master) < 2 {
+				continue
+			}
+			host, _ := redis.String(master[0], nil)
+			port, _ := redis.Int(master[1], nil)
+			addr := net.JoinHostPort(host, strconv.Itoa(port))
+			for slot := start; slot <= end && slot < clusterSlots; slot++ {
+				slots[slot] = addr
+			}
+		}
+
+		self.mu.Lock()
+		self.slots = slots
+		self.mu.Unlock()
+		return nil
+	}
+	return err
+}
+
+// seeds 已知节点优先, 其次为配置的种子节点
+func (self *CacheCluster) seeds() []string {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	seeds := make([]string, 0, len(self.pools)+len(self.config.ClusterAddrs))
+	for addr := range self.pools {
+		seeds = append(seeds, addr)
+	}
+	return append(seeds, self.config.ClusterAddrs...)
+}
+
+func (self *CacheCluster) pool(addr string) *redis.Pool {
+	self.mu.RLock()
+	pool, ok := self.pools[addr]
+	self.mu.RUnlock()
+	if ok {
+		return pool
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	pool, ok = self.pools[addr]
+	if !ok {
+		pool = newRedisPool(self.config, func() (redis.Conn, error) {
+			return dialRedis(addr, self.config.Auth)
+		})
+		self.pools[addr] = pool
+	}
+	return pool
+}
+
+// clusterSlot 计算key的哈希槽, 支持 {hashtag}
+func clusterSlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key)) % clusterSlots
+}
+
+// crc16 CRC16-CCITT(XMODEM), 与Redis集群的实现一致
+func crc16(key string) uint16 {
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,285 @@
+package core
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// FileCacheLockStale 锁文件超过该时间未删除时视为持有的进程已退出
+	FileCacheLockStale         = 10 * time.Second
+	fileCacheLockRetryInterval = 10 * time.Millisecond
+)
+
+// CacheFile 基于本地文件的缓存, 适用于命令行工具等场景.
+// 写入时通过 path+".lock" 锁文件在多个进程间互斥, SetNX 可用于跨进程的锁
+type CacheFile struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileCache(path string) *CacheFile {
+	return &CacheFile{
+		path: path,
+	}
+}
+
+func (self *CacheFile) Set(key string, val interface{}) error {
+	return self.SetEx(key, val, 0)
+}
+
+func (self *CacheFile) SetEx(key string, val interface{}, expires int64) error {
+	item, err := newCacheItem(val, expires)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := self.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	items, err := self.load()
+	if err != nil {
+		return err
+	}
+	items[key] = item
+	return self.save(items)
+}
+
+func (self *CacheFile) SetNX(key string, val interface{}, expires int64) (bool, error) {
+	item, err := newCacheItem(val, expires)
+	if err != nil {
+		return false, err
+	}
+
+	unlock, err := self.lock()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	items, err := self.load()
+	if err != nil {
+		return false, err
+	}
+	if _, ok := items[key]; ok {
+		return false, nil
+	}
+	items[key] = item
+	return true, self.save(items)
+}
+
+func (self *CacheFile) Get(key string) (string, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	items, err := self.load()
+	if err != nil {
+		return "", err
+	}
+	item, ok := items[key]
+	if !ok {
+		return "", ErrCacheMiss
+	}
+	return item.Value, nil
+}
+
+func (self *CacheFile) Exists(key string) bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	items, err := self.load()
+	if err != nil {
+		return false
+	}
+	_, ok := items[key]
+	return ok
+}
+
+func (self *CacheFile) Delete(key string) error {
+	unlock, err := self.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	items, err := self.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := items[key]; !ok {
+		return nil
+	}
+	delete(items, key)
+	return self.save(items)
+}
+
+func (self *CacheFile) DeleteIfEquals(key string, val interface{}) (bool, error) {
+	value, err := json.Marshal(val)
+	if err != nil {
+		return false, err
+	}
+
+	unlock, err := self.lock()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	items, err := self.load()
+	if err != nil {
+		return false, err
+	}
+	item, ok := items[key]
+	if !ok || item.Value != string(value) {
+		return false, nil
+	}
+	delete(items, key)
+	return true, self.save(items)
+}
+
+func (self *CacheFile) TTL(key string) (int64, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	items, err := self.load()
+	if err != nil {
+		return 0, err
+	}
+	item, ok := items[key]
+	if !ok {
+		return -2, nil
+	}
+	return item.ttl(time.Now()), nil
+}
+
+func (self *CacheFile) SAdd(key string, member string) error {
+	unlock, err := self.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	items, err := self.load()
+	if err != nil {
+		return err
+	}
+	item, changed, err := addMember(items[key], member)
+	if err != nil || !changed {
+		return err
+	}
+	items[key] = item
+	return self.save(items)
+}
+
+func (self *CacheFile) SRem(key string, member string) error {
+	unlock, err := self.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	items, err := self.load()
+	if err != nil {
+		return err
+	}
+	item, ok := items[key]
+	if !ok {
+		return nil
+	}
+	item, remaining, err := removeMember(item, member)
+	if err != nil {
+		return err
+	}
+	if remaining == 0 {
+		delete(items, key)
+	} else {
+		items[key] = item
+	}
+	return self.save(items)
+}
+
+func (self *CacheFile) SMembers(key string) ([]string, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	items, err := self.load()
+	if err != nil {
+		return nil, err
+	}
+	return items[key].members()
+}
+
+// lock 进程内加锁后以 O_EXCL 创建锁文件, 多个进程共用缓存文件时串行读写,
+// 锁文件超过 FileCacheLockStale 未删除时视为持有的进程已退出
+func (self *CacheFile) lock() (func(), error) {
+	self.mu.Lock()
+	lockPath := self.path + ".lock"
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_ = f.Close()
+			return func() {
+				_ = os.Remove(lockPath)
+				self.mu.Unlock()
+			}, nil
+		}
+		if !os.IsExist(err) {
+			self.mu.Unlock()
+			return nil, err
+		}
+		info, err := os.Stat(lockPath)
+		if err == nil && time.Since(info.ModTime()) > FileCacheLockStale {
+			_ = os.Remove(lockPath)
+			continue
+		}
+		time.Sleep(fileCacheLockRetryInterval)
+	}
+}
+
+// load 读取缓存文件并去掉已过期的缓存, 文件不存在时返回空缓存
+func (self *CacheFile) load() (map[string]cacheItem, error) {
+	items := make(map[string]cacheItem)
+	buf, err := ioutil.ReadFile(self.path)
+	if os.IsNotExist(err) {
+		return items, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) == 0 {
+		return items, nil
+	}
+	err = json.Unmarshal(buf, &items)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for key, item := range items {
+		if item.expired(now) {
+			delete(items, key)
+		}
+	}
+	return items, nil
+}
+
+// save 先写临时文件再重命名, 避免写入中断导致缓存文件损坏
+func (self *CacheFile) save(items map[string]cacheItem) error {
+	buf, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(self.path), filepath.Base(self.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	_, err = tmp.Write(buf)
+	if err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), self.path)
+}
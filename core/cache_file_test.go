@@ -0,0 +1,129 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFileCaches 多个共用同一缓存文件的实例, 模拟多个进程, 返回的函数删除缓存目录
+func newFileCaches(t *testing.T, n int) ([]*CacheFile, func()) {
+	dir, err := ioutil.TempDir("", "cache_file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "cache.json")
+	caches := make([]*CacheFile, n)
+	for i := range caches {
+		caches[i] = NewFileCache(path)
+	}
+	return caches, func() {
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestFileCacheSetNXAcrossInstances(t *testing.T) {
+	caches, cleanup := newFileCaches(t, 8)
+	defer cleanup()
+	var won int32
+	var wg sync.WaitGroup
+	for _, cache := range caches {
+		wg.Add(1)
+		go func(cache *CacheFile) {
+			defer wg.Done()
+			ok, err := cache.SetNX("lock", 1, 60)
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				atomic.AddInt32(&won, 1)
+			}
+		}(cache)
+	}
+	wg.Wait()
+	if won != 1 {
+		t.Fatalf("expected exactly one instance to win the lock, got %d", won)
+	}
+}
+
+func TestFileCacheWritesAcrossInstances(t *testing.T) {
+	caches, cleanup := newFileCaches(t, 8)
+	defer cleanup()
+	var wg sync.WaitGroup
+	for i, cache := range caches {
+		wg.Add(1)
+		go func(i int, cache *CacheFile) {
+			defer wg.Done()
+			err := cache.SetEx("key"+strconv.Itoa(i), i, 60)
+			if err != nil {
+				t.Error(err)
+			}
+		}(i, cache)
+	}
+	wg.Wait()
+	for i := range caches {
+		if !caches[0].Exists("key" + strconv.Itoa(i)) {
+			t.Fatalf("write of key%d was lost", i)
+		}
+	}
+}
+
+func TestFileCacheRemovesStaleLock(t *testing.T) {
+	caches, cleanup := newFileCaches(t, 1)
+	defer cleanup()
+	cache := caches[0]
+	lockPath := cache.path + ".lock"
+	err := ioutil.WriteFile(lockPath, nil, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := time.Now().Add(-2 * FileCacheLockStale)
+	_ = os.Chtimes(lockPath, stale, stale)
+
+	err = cache.SetEx("key", 1, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Fatal("lock file left behind")
+	}
+}
+
+func TestFileCacheSAddAcrossInstances(t *testing.T) {
+	caches, cleanup := newFileCaches(t, 8)
+	defer cleanup()
+	var wg sync.WaitGroup
+	for i, cache := range caches {
+		wg.Add(1)
+		go func(i int, cache *CacheFile) {
+			defer wg.Done()
+			err := cache.SAdd("set", strconv.Itoa(i))
+			if err != nil {
+				t.Error(err)
+			}
+		}(i, cache)
+	}
+	wg.Wait()
+	members, err := caches[0].SMembers("set")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != len(caches) {
+		t.Fatalf("expected %d members, got %v", len(caches), members)
+	}
+
+	for i := range caches {
+		err = caches[i].SRem("set", strconv.Itoa(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if caches[0].Exists("set") {
+		t.Fatal("empty set was not deleted")
+	}
+}
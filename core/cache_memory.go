@@ -0,0 +1,248 @@
+package core
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// memoryCacheSweepInterval 写入时清理已过期缓存的最短间隔
+const memoryCacheSweepInterval = time.Minute
+
+// CacheMemory 进程内缓存, 适用于测试与单实例部署.
+// 推送的 nonce 与消息去重等只写不读的key在写入时定期清理
+type CacheMemory struct {
+	mu        sync.Mutex
+	items     map[string]cacheItem
+	lastSweep time.Time
+}
+
+type cacheItem struct {
+	Value     string `json:"value"`
+	ExpiresAt int64  `json:"expires_at"` // 过期时间戳, 为0时不过期
+}
+
+func (self cacheItem) expired(now time.Time) bool {
+	return self.ExpiresAt > 0 && now.Unix() >= self.ExpiresAt
+}
+
+func (self cacheItem) ttl(now time.Time) int64 {
+	if self.ExpiresAt == 0 {
+		return -1
+	}
+	return self.ExpiresAt - now.Unix()
+}
+
+// members 集合以JSON数组保存在缓存值中
+func (self cacheItem) members() ([]string, error) {
+	var members []string
+	if self.Value == "" {
+		return members, nil
+	}
+	err := json.Unmarshal([]byte(self.Value), &members)
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// addMember 添加集合成员, 返回新的集合与是否有变化
+func addMember(item cacheItem, member string) (cacheItem, bool, error) {
+	members, err := item.members()
+	if err != nil {
+		return cacheItem{}, false, err
+	}
+	for _, m := range members {
+		if m == member {
+			return item, false, nil
+		}
+	}
+	item, err = newCacheItem(append(members, member), 0)
+	return item, err == nil, err
+}
+
+// removeMember 移除集合成员, 返回剩余成员数与新的集合
+func removeMember(item cacheItem, member string) (cacheItem, int, error) {
+	members, err := item.members()
+	if err != nil {
+		return cacheItem{}, 0, err
+	}
+	remaining := make([]string, 0, len(members))
+	for _, m := range members {
+		if m != member {
+			remaining = append(remaining, m)
+		}
+	}
+	item, err = newCacheItem(remaining, 0)
+	return item, len(remaining), err
+}
+
+func newCacheItem(val interface{}, expires int64) (cacheItem, error) {
+	value, err := json.Marshal(val)
+	if err != nil {
+		return cacheItem{}, err
+	}
+	item := cacheItem{
+		Value: string(value),
+	}
+	if expires > 0 {
+		item.ExpiresAt = time.Now().Unix() + expires
+	}
+	return item, nil
+}
+
+func NewMemoryCache() *CacheMemory {
+	return &CacheMemory{
+		items:     make(map[string]cacheItem),
+		lastSweep: time.Now(),
+	}
+}
+
+func (self *CacheMemory) Set(key string, val interface{}) error {
+	return self.SetEx(key, val, 0)
+}
+
+func (self *CacheMemory) SetEx(key string, val interface{}, expires int64) error {
+	item, err := newCacheItem(val, expires)
+	if err != nil {
+		return err
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.sweep()
+	self.items[key] = item
+	return nil
+}
+
+func (self *CacheMemory) SetNX(key string, val interface{}, expires int64) (bool, error) {
+	item, err := newCacheItem(val, expires)
+	if err != nil {
+		return false, err
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.sweep()
+	if _, ok := self.get(key); ok {
+		return false, nil
+	}
+	self.items[key] = item
+	return true, nil
+}
+
+func (self *CacheMemory) Get(key string) (string, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	item, ok := self.get(key)
+	if !ok {
+		return "", ErrCacheMiss
+	}
+	return item.Value, nil
+}
+
+func (self *CacheMemory) Exists(key string) bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	_, ok := self.get(key)
+	return ok
+}
+
+func (self *CacheMemory) Delete(key string) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	delete(self.items, key)
+	return nil
+}
+
+func (self *CacheMemory) DeleteIfEquals(key string, val interface{}) (bool, error) {
+	value, err := json.Marshal(val)
+	if err != nil {
+		return false, err
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	item, ok := self.get(key)
+	if !ok || item.Value != string(value) {
+		return false, nil
+	}
+	delete(self.items, key)
+	return true, nil
+}
+
+func (self *CacheMemory) TTL(key string) (int64, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	item, ok := self.get(key)
+	if !ok {
+		return -2, nil
+	}
+	return item.ttl(time.Now()), nil
+}
+
+func (self *CacheMemory) SAdd(key string, member string) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.sweep()
+	item, _ := self.get(key)
+	item, changed, err := addMember(item, member)
+	if err != nil || !changed {
+		return err
+	}
+	self.items[key] = item
+	return nil
+}
+
+func (self *CacheMemory) SRem(key string, member string) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	item, ok := self.get(key)
+	if !ok {
+		return nil
+	}
+	item, remaining, err := removeMember(item, member)
+	if err != nil {
+		return err
+	}
+	if remaining == 0 {
+		delete(self.items, key)
+		return nil
+	}
+	self.items[key] = item
+	return nil
+}
+
+func (self *CacheMemory) SMembers(key string) ([]string, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	item, _ := self.get(key)
+	return item.members()
+}
+
+// get 读取未过期的缓存, 已过期的缓存在读取时删除
+func (self *CacheMemory) get(key string) (cacheItem, bool) {
+	item, ok := self.items[key]
+	if !ok {
+		return cacheItem{}, false
+	}
+	if item.expired(time.Now()) {
+		delete(self.items, key)
+		return cacheItem{}, false
+	}
+	return item, true
+}
+
+// sweep 距上次清理超过 memoryCacheSweepInterval 时删除所有已过期的缓存
+func (self *CacheMemory) sweep() {
+	now := time.Now()
+	if now.Sub(self.lastSweep) < memoryCacheSweepInterval {
+		return
+	}
+	self.lastSweep = now
+	for key, item := range self.items {
+		if item.expired(now) {
+			delete(self.items, key)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheMemorySweepsUnreadExpiredKeys(t *testing.T) {
+	cache := NewMemoryCache()
+	for _, key := range []string{NonceCacheKeyPrefix + "1:a", NonceCacheKeyPrefix + "2:b", MessageDedupCacheKeyPrefix + "c"} {
+		ok, err := cache.SetNX(key, 1, 60)
+		if err != nil || !ok {
+			t.Fatalf("SetNX %s: ok=%v err=%v", key, ok, err)
+		}
+	}
+	err := cache.Set("permanent", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟已过期且距上次清理超过间隔
+	cache.mu.Lock()
+	for key, item := range cache.items {
+		if item.ExpiresAt > 0 {
+			item.ExpiresAt = time.Now().Unix() - 1
+			cache.items[key] = item
+		}
+	}
+	cache.lastSweep = time.Now().Add(-memoryCacheSweepInterval)
+	cache.mu.Unlock()
+
+	_, err = cache.SetNX(NonceCacheKeyPrefix+"3:d", 1, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if len(cache.items) != 2 {
+		t.Fatalf("expected expired keys to be swept, got %d items", len(cache.items))
+	}
+	if _, ok := cache.items["permanent"]; !ok {
+		t.Fatal("sweep removed a key without expiry")
+	}
+}
+
+func TestCacheMemorySweepIsRateLimited(t *testing.T) {
+	cache := NewMemoryCache()
+	cache.mu.Lock()
+	cache.items["expired"] = cacheItem{Value: "1", ExpiresAt: time.Now().Unix() - 1}
+	cache.mu.Unlock()
+
+	err := cache.SetEx("other", 1, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if _, ok := cache.items["expired"]; !ok {
+		t.Fatal("sweep ran before memoryCacheSweepInterval")
+	}
+}
+
+func TestCacheMemorySet(t *testing.T) {
+	cache := NewMemoryCache()
+	members, err := cache.SMembers("set")
+	if err != nil || len(members) != 0 {
+		t.Fatalf("missing set: members=%v err=%v", members, err)
+	}
+	for _, member := range []string{"a", "b", "a"} {
+		err = cache.SAdd("set", member)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	members, err = cache.SMembers("set")
+	if err != nil || len(members) != 2 {
+		t.Fatalf("expected [a b], got %v err=%v", members, err)
+	}
+	for _, member := range []string{"c", "a", "b"} {
+		err = cache.SRem("set", member)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cache.Exists("set") {
+		t.Fatal("empty set was not deleted")
+	}
+
+	_ = cache.Set("value", "text")
+	if err = cache.SAdd("value", "a"); err == nil {
+		t.Fatal("expected an error adding to a non-set value")
+	}
+}
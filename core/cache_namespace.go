@@ -0,0 +1,69 @@
+package core
+
+// CacheNamespace 为所有key添加命名空间前缀, 多个环境共用同一个Redis时避免冲突
+type CacheNamespace struct {
+	Cache     Cache
+	Namespace string
+}
+
+// WithNamespace namespace 为空时直接返回 cache
+func WithNamespace(cache Cache, namespace string) Cache {
+	if namespace == "" || cache == nil {
+		return cache
+	}
+	if namespaced, ok := cache.(*CacheNamespace); ok && namespaced.Namespace == namespace {
+		return cache
+	}
+	return &CacheNamespace{
+		Cache:     cache,
+		Namespace: namespace,
+	}
+}
+
+func (self *CacheNamespace) Set(key string, val interface{}) error {
+	return self.Cache.Set(self.key(key), val)
+}
+
+func (self *CacheNamespace) SetEx(key string, val interface{}, expires int64) error {
+	return self.Cache.SetEx(self.key(key), val, expires)
+}
+
+func (self *CacheNamespace) SetNX(key string, val interface{}, expires int64) (bool, error) {
+	return self.Cache.SetNX(self.key(key), val, expires)
+}
+
+func (self *CacheNamespace) Get(key string) (string, error) {
+	return self.Cache.Get(self.key(key))
+}
+
+func (self *CacheNamespace) Exists(key string) bool {
+	return self.Cache.Exists(self.key(key))
+}
+
+func (self *CacheNamespace) Delete(key string) error {
+	return self.Cache.Delete(self.key(key))
+}
+
+func (self *CacheNamespace) DeleteIfEquals(key string, val interface{}) (bool, error) {
+	return self.Cache.DeleteIfEquals(self.key(key), val)
+}
+
+func (self *CacheNamespace) TTL(key string) (int64, error) {
+	return self.Cache.TTL(self.key(key))
+}
+
+func (self *CacheNamespace) SAdd(key string, member string) error {
+	return self.Cache.SAdd(self.key(key), member)
+}
+
+func (self *CacheNamespace) SRem(key string, member string) error {
+	return self.Cache.SRem(self.key(key), member)
+}
+
+func (self *CacheNamespace) SMembers(key string) ([]string, error) {
+	return self.Cache.SMembers(self.key(key))
+}
+
+func (self *CacheNamespace) key(key string) string {
+	return self.Namespace + ":" + key
+}
@@ -1,5 +1,7 @@
 package core
 
+import "time"
+
 type IClient interface {
 	GetToken() (map[string]interface{}, error)
 	RefreshToken() (map[string]interface{}, error)
@@ -11,4 +13,11 @@ type ClientConfig struct {
 	Token     string
 	AesKey    string
 	BaseUrl   string
+	Timeout   time.Duration // 请求超时时间, 为0时使用 DefaultHttpTimeout
+	// DistributedLock 多实例部署时通过缓存锁刷新component_access_token
+	DistributedLock bool
+	// Namespace 缓存key的命名空间, 多个环境共用同一个Redis时设置
+	Namespace string
+	// ReplayWindow 推送 timestamp 的有效范围, 为0时使用 DefaultReplayWindow, 小于0时不校验
+	ReplayWindow time.Duration
 }
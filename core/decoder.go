@@ -1,9 +1,11 @@
 package core
 
 import (
+	"crypto/rand"
 	"encoding/xml"
-	"github.com/conetse/WXBizMsgCrypt"
 	"github.com/mrwangjinjin/go-wechat/internal/util"
+	"github.com/mrwangjinjin/go-wechat/pkg/crypto"
+	"strconv"
 	"time"
 )
 
@@ -35,10 +37,16 @@ type EventHeaderMessage struct {
 
 type EventMessage struct {
 	EventHeaderMessage
-	MsgId   int64  `xml:"MsgId"`
-	Event   string `xml:"Event"`
-	Reason  string `xml:"Reason"`
-	Content string `xml:"Content"`
+	MsgId    int64  `xml:"MsgId"`
+	Event    string `xml:"Event"`
+	EventKey string `xml:"EventKey"`
+	Reason   string `xml:"Reason"`
+	Content  string `xml:"Content"`
+
+	// Payload 按 MsgType/Event 解析的具体消息, 如 *TextMessage、*SubscribeEvent, 未知类型时为 nil
+	Payload interface{} `xml:"-"`
+	// AuthorizerAppId 消息所属的授权方 appid, 无法确定时为空
+	AuthorizerAppId string `xml:"-"`
 }
 
 type NotifyHeaderMessage struct {
@@ -51,9 +59,9 @@ type NotifyHeaderMessage struct {
 
 type InfoMessage struct {
 	XMLName            xml.Name `xml:"info"`
-	Name               int64    `xml:"name"`
+	Name               string   `xml:"name"`
 	Code               string   `xml:"code"`
-	CodeType           int      `xml:code_type`
+	CodeType           int      `xml:"code_type"`
 	LegalPersonaWechat string   `xml:"legal_persona_wechat"`
 	LegalPersonaName   string   `xml:"legal_persona_name"`
 	ComponentPhone     string   `xml:"component_phone"`
@@ -61,14 +69,16 @@ type InfoMessage struct {
 
 type NotifyMessage struct {
 	NotifyHeaderMessage
-	ComponentVerifyTicket string `xml:"ComponentVerifyTicket"`
-	AuthorizerAppid       string `xml:"AuthorizerAppid"`
-	AuthorizationCode     string `xml:"AuthorizationCode"`
-	PreAuthCode           string `xml:"PreAuthCode"`
-	Appid                 string `xml:"appid"`
-	AuthCode              string `xml:"auth_code"`
-	Status                int    `xml:"status"`
-	InfoMessage
+	ComponentVerifyTicket        string      `xml:"ComponentVerifyTicket"`
+	AuthorizerAppid              string      `xml:"AuthorizerAppid"`
+	AuthorizationCode            string      `xml:"AuthorizationCode"`
+	AuthorizationCodeExpiredTime int64       `xml:"AuthorizationCodeExpiredTime"`
+	PreAuthCode                  string      `xml:"PreAuthCode"`
+	Appid                        string      `xml:"appid"`
+	AuthCode                     string      `xml:"auth_code"`
+	Status                       int         `xml:"status"`
+	Msg                          string      `xml:"msg"`
+	Info                         InfoMessage `xml:"info"`
 }
 
 type MessageDecoder struct {
@@ -119,49 +129,83 @@ func (self *MessageDecoder) VerifySignature(token string) bool {
 	return true
 }
 
-func (self *MessageDecoder) DecodeComponentVerifyTicket(appId, aesKey string) (NotifyMessage, error) {
+// Decrypt 解密消息体, 返回明文XML
+func (self *MessageDecoder) Decrypt(appId, aesKey string) ([]byte, error) {
 	var msg CipherRequestHttpBody
 	err := xml.Unmarshal(self.EncryptMsg, &msg)
 	if err != nil {
-		return NotifyMessage{}, err
+		return nil, newServeError(ErrUnknownMessage, err)
 	}
 	random, msgPlaintext, err := util.DecryptMsg(appId, aesKey, string(msg.Base64EncryptedMsg))
 	if err != nil {
-		return NotifyMessage{}, err
+		return nil, newServeError(ErrDecrypt, err)
 	}
 	self.Random = random
+	return msgPlaintext, nil
+}
+
+func (self *MessageDecoder) DecodeComponentVerifyTicket(appId, aesKey string) (NotifyMessage, error) {
+	msgPlaintext, err := self.Decrypt(appId, aesKey)
+	if err != nil {
+		return NotifyMessage{}, err
+	}
 	var ticketMsg NotifyMessage
 	err = xml.Unmarshal(msgPlaintext, &ticketMsg)
 	if err != nil {
-		return NotifyMessage{}, err
+		return NotifyMessage{}, newServeError(ErrUnknownMessage, err)
 	}
 	return ticketMsg, nil
 }
 
 func (self *MessageDecoder) DecodeEventMessage(appId, aesKey string) (EventMessage, error) {
-	var msg CipherRequestHttpBody
-	err := xml.Unmarshal(self.EncryptMsg, &msg)
-	if err != nil {
-		return EventMessage{}, err
-	}
-	random, msgPlaintext, err := util.DecryptMsg(appId, aesKey, string(msg.Base64EncryptedMsg))
+	msgPlaintext, err := self.Decrypt(appId, aesKey)
 	if err != nil {
 		return EventMessage{}, err
 	}
-	self.Random = random
-	var eventMsg EventMessage
-	err = xml.Unmarshal(msgPlaintext, &eventMsg)
+	eventMsg, err := ParseEventMessage(msgPlaintext)
 	if err != nil {
-		return EventMessage{}, err
+		return EventMessage{}, newServeError(ErrUnknownMessage, err)
 	}
 	return eventMsg, nil
 }
 
+// EncodeMessage 加密 RawMsg 并签名, 返回序列化后的 CipherResponseHttpBody
 func (self *MessageEncoder) EncodeMessage(appId, token, aesKey string) (string, error) {
-	cry, err := WXBizMsgCrypt.NewWXBizMsgCrypt(token, aesKey, appId)
+	body, err := self.EncryptMessage(appId, token, aesKey)
+	if err != nil {
+		return "", err
+	}
+	buf, err := xml.Marshal(body)
 	if err != nil {
 		return "", err
 	}
-	_, str := cry.EncryptMsg(string(self.RawMsg), self.Nonce, time.Now().Unix())
-	return str, nil
+	return string(buf), nil
+}
+
+// EncryptMessage 加密 RawMsg 并签名, Random 为空时随机生成, Timestamp 为空时取当前时间
+func (self *MessageEncoder) EncryptMessage(appId, token, aesKey string) (CipherResponseHttpBody, error) {
+	crypter, err := crypto.NewMsgCrypter(token, aesKey, appId)
+	if err != nil {
+		return CipherResponseHttpBody{}, err
+	}
+	if len(self.Random) == 0 {
+		self.Random = make([]byte, 16)
+		_, err = rand.Read(self.Random)
+		if err != nil {
+			return CipherResponseHttpBody{}, err
+		}
+	}
+	if self.Timestamp == "" {
+		self.Timestamp = strconv.FormatInt(time.Now().Unix(), 10)
+	}
+	encryptMsg, err := crypter.EncryptWithRandom(self.Random, self.RawMsg)
+	if err != nil {
+		return CipherResponseHttpBody{}, err
+	}
+	return CipherResponseHttpBody{
+		Encrypt:      encryptMsg,
+		MsgSignature: crypter.Sign(self.Timestamp, self.Nonce, encryptMsg),
+		TimeStamp:    self.Timestamp,
+		Nonce:        self.Nonce,
+	}, nil
 }
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"log"
+	"strconv"
+)
+
+const (
+	MessageDedupCacheKeyPrefix = "CACHE_MESSAGE@@"
+)
+
+// MessageDedupExpires 微信在5秒内未收到响应时重试3次, 去重记录保留的秒数
+const MessageDedupExpires = 60
+
+// dedupKey 消息以 MsgId 去重, 事件没有 MsgId 时以 FromUserName+CreateTime+Event+EventKey 去重,
+// 同一用户同一秒内的不同事件(如上报地理位置与点击菜单)不会被合并
+func dedupKey(message *EventMessage) string {
+	if message.MsgId != 0 {
+		return message.ToUserName + ":" + strconv.FormatInt(message.MsgId, 10)
+	}
+	return message.ToUserName + ":" + message.FromUserName + ":" + strconv.FormatInt(message.CreateTime, 10) +
+		":" + message.Event + ":" + message.EventKey
+}
+
+// isDuplicate 消息已处理过时返回 true, 缓存不可用时不去重
+func (self *Server) isDuplicate(message *EventMessage) bool {
+	if self.Cache == nil {
+		return false
+	}
+	ok, err := self.Cache.SetNX(MessageDedupCacheKeyPrefix+dedupKey(message), 1, MessageDedupExpires)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	return !ok
+}
+
+// forgetMessage 删除去重记录, 用于推送未能处理的情况
+func (self *Server) forgetMessage(message *EventMessage) {
+	if self.Cache == nil {
+		return
+	}
+	err := self.Cache.Delete(MessageDedupCacheKeyPrefix + dedupKey(message))
+	if err != nil {
+		log.Println(err)
+	}
+}
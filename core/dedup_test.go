@@ -0,0 +1,148 @@
+package core
+
+import (
+	"github.com/mrwangjinjin/go-wechat/internal/util"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer() *Server {
+	return NewServer(&ClientConfig{
+		AppId:  "wx_component",
+		Token:  "token",
+		AesKey: "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG",
+	}, NewMemoryCache())
+}
+
+// newPlainPush 构造明文模式的推送请求
+func newPlainPush(server *Server, body string) *http.Request {
+	return newPlainPushWithNonce(server, "12345", body)
+}
+
+func newPlainPushWithNonce(server *Server, nonce, body string) *http.Request {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	query := url.Values{
+		"timestamp": {timestamp},
+		"nonce":     {nonce},
+		"signature": {util.Sign(server.Token, timestamp, nonce)},
+	}
+	return httptest.NewRequest(http.MethodPost, "/event?"+query.Encode(), strings.NewReader(body))
+}
+
+func TestDedupKeySeparatesEventsInSameSecond(t *testing.T) {
+	header := EventHeaderMessage{ToUserName: "gh_1", FromUserName: "o_user", CreateTime: 1600000000, MsgType: MsgTypeEvent}
+	location := &EventMessage{EventHeaderMessage: header, Event: EventLocation}
+	click := &EventMessage{EventHeaderMessage: header, Event: EventClick, EventKey: "V1001"}
+	view := &EventMessage{EventHeaderMessage: header, Event: EventClick, EventKey: "V1002"}
+	if dedupKey(location) == dedupKey(click) {
+		t.Fatal("LOCATION and CLICK in the same second share a dedup key")
+	}
+	if dedupKey(click) == dedupKey(view) {
+		t.Fatal("clicks on different menus share a dedup key")
+	}
+}
+
+func TestSyncHandlerPanicAllowsRetry(t *testing.T) {
+	server := newTestServer()
+	body := "<xml><ToUserName><![CDATA[gh_1]]></ToUserName><FromUserName><![CDATA[o_user]]></FromUserName>" +
+		"<CreateTime>1600000000</CreateTime><MsgType><![CDATA[text]]></MsgType><Content><![CDATA[hi]]></Content><MsgId>1001</MsgId></xml>"
+	calls := 0
+	handler := func(message *EventMessage) Reply {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		return nil
+	}
+
+	w := httptest.NewRecorder()
+	server.EventReplyServe(w, newPlainPush(server, body), handler)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 on panic, got %d", w.Code)
+	}
+
+	// 微信使用相同的 nonce 与消息重试
+	w = httptest.NewRecorder()
+	server.EventReplyServe(w, newPlainPush(server, body), handler)
+	if w.Code != http.StatusOK || calls != 2 {
+		t.Fatalf("retry was not handled: status=%d calls=%d", w.Code, calls)
+	}
+
+	// 处理成功后的重试回复 success, 不再调用处理函数
+	w = httptest.NewRecorder()
+	server.EventReplyServe(w, newPlainPush(server, body), handler)
+	if w.Code != http.StatusOK || w.Body.String() != "success" || calls != 2 {
+		t.Fatalf("duplicate was not acknowledged once: status=%d body=%q calls=%d", w.Code, w.Body.String(), calls)
+	}
+}
+
+func TestRetryAfterSuccessIsAcknowledged(t *testing.T) {
+	event := "<xml><ToUserName><![CDATA[gh_1]]></ToUserName><FromUserName><![CDATA[o_user]]></FromUserName>" +
+		"<CreateTime>1600000000</CreateTime><MsgType><![CDATA[text]]></MsgType><Content><![CDATA[hi]]></Content><MsgId>1002</MsgId></xml>"
+	notify := "<xml><AppId><![CDATA[wx_component]]></AppId><CreateTime>1600000000</CreateTime>" +
+		"<InfoType><![CDATA[unauthorized]]></InfoType><AuthorizerAppid><![CDATA[wx_authorizer]]></AuthorizerAppid></xml>"
+	tests := []struct {
+		name     string
+		body     string
+		register func(server *Server, handled func())
+		serve    func(server *Server, w http.ResponseWriter, r *http.Request)
+	}{
+		{
+			name: "EventServe",
+			body: event,
+			register: func(server *Server, handled func()) {
+				server.OnEvent(func(message *EventMessage) Reply {
+					handled()
+					return nil
+				})
+			},
+			serve: func(server *Server, w http.ResponseWriter, r *http.Request) {
+				server.EventServe(w, r, nil)
+			},
+		},
+		{
+			name: "Serve",
+			body: notify,
+			register: func(server *Server, handled func()) {
+				server.OnUnauthorized(func(event *UnauthorizedEvent) {
+					handled()
+				})
+			},
+			serve: func(server *Server, w http.ResponseWriter, r *http.Request) {
+				server.Serve(w, r, nil)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer()
+			var errs []error
+			server.OnError(func(r *http.Request, err error) {
+				errs = append(errs, err)
+			})
+			calls := 0
+			tt.register(server, func() {
+				calls++
+			})
+			// 微信未及时收到响应时使用相同的 nonce 重试
+			for i := 0; i < 2; i++ {
+				w := httptest.NewRecorder()
+				tt.serve(server, w, newPlainPush(server, tt.body))
+				if w.Code != http.StatusOK || w.Body.String() != "success" {
+					t.Fatalf("delivery %d: status=%d body=%q", i+1, w.Code, w.Body.String())
+				}
+			}
+			if calls != 1 {
+				t.Fatalf("expected handler to run once, got %d", calls)
+			}
+			if len(errs) != 0 {
+				t.Fatalf("retry reported as error: %v", errs)
+			}
+		})
+	}
+}
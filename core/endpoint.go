@@ -92,7 +92,7 @@ func (self *Endpoint) GetTemplateList(authorizerAccessToken string) string {
 }
 
 func (self *Endpoint) GetQrCode(authorizerAccessToken, path string) string {
-	return fmt.Sprintf("%s/wxa/get_qrcode?access_token=%s&path=%s", self.baseUrl, authorizerAccessToken)
+	return fmt.Sprintf("%s/wxa/get_qrcode?access_token=%s&path=%s", self.baseUrl, authorizerAccessToken, path)
 }
 
 func (self *Endpoint) GetQrCodeWithoutPath(authorizerAccessToken string) string {
@@ -0,0 +1,152 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 微信接口错误码
+const (
+	ErrCodeInvalidCredential  = 40001 // access_token 无效
+	ErrCodeAccessTokenExpired = 42001 // access_token 超时
+	ErrCodeApiFreqOutOfLimit  = 45009 // 接口调用超过限制
+	ErrCodeInvalidTicket      = 61006 // component_verify_ticket 无效
+)
+
+// APIError 微信接口错误
+type APIError struct {
+	Code       int    // errcode
+	Message    string // errmsg
+	HTTPStatus int    // HTTP状态码, 网络错误时为0
+	RequestID  string // errmsg 中的 rid
+	Endpoint   string // 接口路径, 不含 access_token 等参数
+	Err        error  // 网络或解析错误
+}
+
+func (self *APIError) Error() string {
+	if self.Err != nil {
+		return fmt.Sprintf("网络错误: %s: %v", self.Endpoint, self.Err)
+	}
+	if self.HTTPStatus != http.StatusOK {
+		return fmt.Sprintf("网络错误: %s: http status %d", self.Endpoint, self.HTTPStatus)
+	}
+	return fmt.Sprintf("操作失败: %s: errcode=%d, errmsg=%s", self.Endpoint, self.Code, self.Message)
+}
+
+func (self *APIError) Unwrap() error {
+	return self.Err
+}
+
+// IsTokenExpired access_token 无效或已过期
+func (self *APIError) IsTokenExpired() bool {
+	return self.Code == ErrCodeInvalidCredential || self.Code == ErrCodeAccessTokenExpired
+}
+
+// IsRateLimited 接口调用超过限制
+func (self *APIError) IsRateLimited() bool {
+	return self.Code == ErrCodeApiFreqOutOfLimit
+}
+
+// IsInvalidTicket component_verify_ticket 无效
+func (self *APIError) IsInvalidTicket() bool {
+	return self.Code == ErrCodeInvalidTicket
+}
+
+// NewAPIError 包装网络错误, *url.Error 中的URL去掉参数
+func NewAPIError(rawUrl string, err error) *APIError {
+	return &APIError{
+		Endpoint: endpointPath(rawUrl),
+		Err:      redactURLError(err),
+	}
+}
+
+// CheckResponse 检查HTTP状态码与errcode, 失败时返回 *APIError
+func CheckResponse(rawUrl string, status int, body []byte) error {
+	if status != http.StatusOK {
+		return &APIError{
+			HTTPStatus: status,
+			Endpoint:   endpointPath(rawUrl),
+		}
+	}
+	var resp struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	err := json.Unmarshal(body, &resp)
+	if err != nil {
+		return &APIError{
+			HTTPStatus: status,
+			Endpoint:   endpointPath(rawUrl),
+			Err:        err,
+		}
+	}
+	if resp.ErrCode == 0 {
+		return nil
+	}
+	return &APIError{
+		Code:       resp.ErrCode,
+		Message:    resp.ErrMsg,
+		HTTPStatus: status,
+		RequestID:  requestId(resp.ErrMsg),
+		Endpoint:   endpointPath(rawUrl),
+	}
+}
+
+// IsTokenExpired 判断错误是否为 access_token 无效或已过期
+func IsTokenExpired(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.IsTokenExpired()
+}
+
+// IsRateLimited 判断错误是否为接口调用超过限制
+func IsRateLimited(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.IsRateLimited()
+}
+
+// IsInvalidTicket 判断错误是否为 component_verify_ticket 无效
+func IsInvalidTicket(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.IsInvalidTicket()
+}
+
+// endpointPath 去掉URL中的参数, 避免令牌出现在日志中
+func endpointPath(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return ""
+	}
+	return u.Path
+}
+
+// redactURLError http.Client 返回的 *url.Error 包含完整URL, 去掉其中的 access_token 等参数
+func redactURLError(err error) error {
+	urlErr, ok := err.(*url.Error)
+	if !ok {
+		return err
+	}
+	redacted := *urlErr
+	u, parseErr := url.Parse(urlErr.URL)
+	if parseErr != nil {
+		redacted.URL = ""
+	} else {
+		u.RawQuery = ""
+		u.Fragment = ""
+		u.User = nil
+		redacted.URL = u.String()
+	}
+	return &redacted
+}
+
+// requestId 解析 errmsg 末尾的 "rid: xxx"
+func requestId(errMsg string) string {
+	index := strings.LastIndex(errMsg, "rid:")
+	if index < 0 {
+		return ""
+	}
+	return strings.TrimSpace(errMsg[index+len("rid:"):])
+}
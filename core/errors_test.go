@@ -0,0 +1,21 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewAPIErrorRedactsToken(t *testing.T) {
+	rawUrl := "http://127.0.0.1:1/wxa/bind_tester?access_token=SECRET_ACCESS_TOKEN"
+	_, _, err := NewHttpClient().Post(rawUrl, "application/json", []byte("{}"))
+	if err == nil {
+		t.Fatal("expected network error")
+	}
+	apiErr := NewAPIError(rawUrl, err)
+	if strings.Contains(apiErr.Error(), "SECRET_ACCESS_TOKEN") {
+		t.Fatalf("token leaked: %s", apiErr.Error())
+	}
+	if !strings.Contains(apiErr.Error(), "/wxa/bind_tester") {
+		t.Fatalf("endpoint missing: %s", apiErr.Error())
+	}
+}
@@ -0,0 +1,290 @@
+package core
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// 消息类型
+const (
+	MsgTypeText            = "text"
+	MsgTypeImage           = "image"
+	MsgTypeVoice           = "voice"
+	MsgTypeVideo           = "video"
+	MsgTypeShortVideo      = "shortvideo"
+	MsgTypeLocation        = "location"
+	MsgTypeLink            = "link"
+	MsgTypeMiniProgramPage = "miniprogrampage"
+	MsgTypeEvent           = "event"
+)
+
+// 公众号与小程序事件
+const (
+	EventSubscribe             = "subscribe"
+	EventUnsubscribe           = "unsubscribe"
+	EventScan                  = "SCAN"
+	EventLocation              = "LOCATION"
+	EventClick                 = "CLICK"
+	EventView                  = "VIEW"
+	EventTemplateSendJobFinish = "TEMPLATESENDJOBFINISH"
+	EventWeappAuditSuccess     = "weapp_audit_success"
+	EventWeappAuditFail        = "weapp_audit_fail"
+	EventWeappAuditDelay       = "weapp_audit_delay"
+	EventSubscribeMsgPopup     = "subscribe_msg_popup_event"
+	EventSubscribeMsgChange    = "subscribe_msg_change_event"
+	EventSubscribeMsgSent      = "subscribe_msg_sent_event"
+)
+
+// TextMessage 文本消息
+type TextMessage struct {
+	EventHeaderMessage
+	MsgId   int64  `xml:"MsgId"`
+	Content string `xml:"Content"`
+}
+
+// ImageMessage 图片消息
+type ImageMessage struct {
+	EventHeaderMessage
+	MsgId   int64  `xml:"MsgId"`
+	PicUrl  string `xml:"PicUrl"`
+	MediaId string `xml:"MediaId"`
+}
+
+// VoiceMessage 语音消息, 开通语音识别后 Recognition 为识别结果
+type VoiceMessage struct {
+	EventHeaderMessage
+	MsgId       int64  `xml:"MsgId"`
+	MediaId     string `xml:"MediaId"`
+	Format      string `xml:"Format"`
+	Recognition string `xml:"Recognition"`
+}
+
+// VideoMessage 视频与小视频消息
+type VideoMessage struct {
+	EventHeaderMessage
+	MsgId        int64  `xml:"MsgId"`
+	MediaId      string `xml:"MediaId"`
+	ThumbMediaId string `xml:"ThumbMediaId"`
+}
+
+// LocationMessage 地理位置消息
+type LocationMessage struct {
+	EventHeaderMessage
+	MsgId     int64   `xml:"MsgId"`
+	LocationX float64 `xml:"Location_X"`
+	LocationY float64 `xml:"Location_Y"`
+	Scale     int     `xml:"Scale"`
+	Label     string  `xml:"Label"`
+}
+
+// LinkMessage 链接消息
+type LinkMessage struct {
+	EventHeaderMessage
+	MsgId       int64  `xml:"MsgId"`
+	Title       string `xml:"Title"`
+	Description string `xml:"Description"`
+	Url         string `xml:"Url"`
+}
+
+// MiniProgramPageMessage 小程序卡片消息
+type MiniProgramPageMessage struct {
+	EventHeaderMessage
+	MsgId        int64  `xml:"MsgId"`
+	Title        string `xml:"Title"`
+	AppId        string `xml:"AppId"`
+	PagePath     string `xml:"PagePath"`
+	ThumbUrl     string `xml:"ThumbUrl"`
+	ThumbMediaId string `xml:"ThumbMediaId"`
+}
+
+// SubscribeEvent 关注事件, 扫描带参数二维码关注时 EventKey 为 qrscene_ 前缀的场景值
+type SubscribeEvent struct {
+	EventHeaderMessage
+	Event    string `xml:"Event"`
+	EventKey string `xml:"EventKey"`
+	Ticket   string `xml:"Ticket"`
+}
+
+// UnsubscribeEvent 取消关注事件
+type UnsubscribeEvent struct {
+	EventHeaderMessage
+	Event string `xml:"Event"`
+}
+
+// ScanEvent 已关注用户扫描带参数二维码
+type ScanEvent struct {
+	EventHeaderMessage
+	Event    string `xml:"Event"`
+	EventKey string `xml:"EventKey"`
+	Ticket   string `xml:"Ticket"`
+}
+
+// LocationEvent 上报地理位置事件
+type LocationEvent struct {
+	EventHeaderMessage
+	Event     string  `xml:"Event"`
+	Latitude  float64 `xml:"Latitude"`
+	Longitude float64 `xml:"Longitude"`
+	Precision float64 `xml:"Precision"`
+}
+
+// ClickEvent 点击菜单拉取消息
+type ClickEvent struct {
+	EventHeaderMessage
+	Event    string `xml:"Event"`
+	EventKey string `xml:"EventKey"`
+}
+
+// ViewEvent 点击菜单跳转链接
+type ViewEvent struct {
+	EventHeaderMessage
+	Event    string `xml:"Event"`
+	EventKey string `xml:"EventKey"`
+	MenuId   int64  `xml:"MenuId"`
+}
+
+// TemplateSendJobFinishEvent 模板消息发送结果
+type TemplateSendJobFinishEvent struct {
+	EventHeaderMessage
+	Event  string `xml:"Event"`
+	MsgId  int64  `xml:"MsgID"`
+	Status string `xml:"Status"`
+}
+
+// WeappAuditSuccessEvent 小程序审核通过
+type WeappAuditSuccessEvent struct {
+	EventHeaderMessage
+	Event    string `xml:"Event"`
+	SuccTime int64  `xml:"SuccTime"`
+}
+
+// WeappAuditFailEvent 小程序审核不通过
+type WeappAuditFailEvent struct {
+	EventHeaderMessage
+	Event      string `xml:"Event"`
+	Reason     string `xml:"Reason"`
+	FailTime   int64  `xml:"FailTime"`
+	ScreenShot string `xml:"ScreenShot"`
+}
+
+// WeappAuditDelayEvent 小程序审核延后
+type WeappAuditDelayEvent struct {
+	EventHeaderMessage
+	Event     string `xml:"Event"`
+	Reason    string `xml:"Reason"`
+	DelayTime int64  `xml:"DelayTime"`
+}
+
+// SubscribeMsgPopupItem 订阅消息弹框结果
+type SubscribeMsgPopupItem struct {
+	TemplateId            string `xml:"TemplateId"`
+	SubscribeStatusString string `xml:"SubscribeStatusString"`
+	PopupScene            int    `xml:"PopupScene"`
+}
+
+// SubscribeMsgPopupEvent 用户操作订阅消息弹框
+type SubscribeMsgPopupEvent struct {
+	EventHeaderMessage
+	Event string                  `xml:"Event"`
+	List  []SubscribeMsgPopupItem `xml:"SubscribeMsgPopupEvent>List"`
+}
+
+// SubscribeMsgChangeItem 订阅状态变更
+type SubscribeMsgChangeItem struct {
+	TemplateId            string `xml:"TemplateId"`
+	SubscribeStatusString string `xml:"SubscribeStatusString"`
+}
+
+// SubscribeMsgChangeEvent 用户管理订阅消息
+type SubscribeMsgChangeEvent struct {
+	EventHeaderMessage
+	Event string                   `xml:"Event"`
+	List  []SubscribeMsgChangeItem `xml:"SubscribeMsgChangeEvent>List"`
+}
+
+// SubscribeMsgSentItem 订阅消息发送结果
+type SubscribeMsgSentItem struct {
+	TemplateId  string `xml:"TemplateId"`
+	MsgId       string `xml:"MsgID"`
+	ErrorCode   int    `xml:"ErrorCode"`
+	ErrorStatus string `xml:"ErrorStatus"`
+}
+
+// SubscribeMsgSentEvent 订阅消息发送结果
+type SubscribeMsgSentEvent struct {
+	EventHeaderMessage
+	Event string                 `xml:"Event"`
+	List  []SubscribeMsgSentItem `xml:"SubscribeMsgSentEvent>List"`
+}
+
+// ParseEventMessage 解析消息与事件, Payload 按 MsgType/Event 解析为对应结构,
+// 未知类型时 Payload 为 nil
+func ParseEventMessage(plaintext []byte) (EventMessage, error) {
+	var eventMsg EventMessage
+	err := xml.Unmarshal(plaintext, &eventMsg)
+	if err != nil {
+		return EventMessage{}, err
+	}
+	payload := newEventPayload(eventMsg.MsgType, eventMsg.Event)
+	if payload != nil {
+		err = xml.Unmarshal(plaintext, payload)
+		if err != nil {
+			return EventMessage{}, err
+		}
+		eventMsg.Payload = payload
+	}
+	return eventMsg, nil
+}
+
+func newEventPayload(msgType, event string) interface{} {
+	switch msgType {
+	case MsgTypeText:
+		return &TextMessage{}
+	case MsgTypeImage:
+		return &ImageMessage{}
+	case MsgTypeVoice:
+		return &VoiceMessage{}
+	case MsgTypeVideo, MsgTypeShortVideo:
+		return &VideoMessage{}
+	case MsgTypeLocation:
+		return &LocationMessage{}
+	case MsgTypeLink:
+		return &LinkMessage{}
+	case MsgTypeMiniProgramPage:
+		return &MiniProgramPageMessage{}
+	case MsgTypeEvent:
+		switch event {
+		case EventSubscribe:
+			return &SubscribeEvent{}
+		case EventUnsubscribe:
+			return &UnsubscribeEvent{}
+		case EventScan:
+			return &ScanEvent{}
+		case EventLocation:
+			return &LocationEvent{}
+		case EventClick:
+			return &ClickEvent{}
+		case EventView:
+			return &ViewEvent{}
+		case EventTemplateSendJobFinish:
+			return &TemplateSendJobFinishEvent{}
+		case EventWeappAuditSuccess:
+			return &WeappAuditSuccessEvent{}
+		case EventWeappAuditFail:
+			return &WeappAuditFailEvent{}
+		case EventWeappAuditDelay:
+			return &WeappAuditDelayEvent{}
+		case EventSubscribeMsgPopup:
+			return &SubscribeMsgPopupEvent{}
+		case EventSubscribeMsgChange:
+			return &SubscribeMsgChangeEvent{}
+		case EventSubscribeMsgSent:
+			return &SubscribeMsgSentEvent{}
+		}
+		// 事件名大小写在不同文档中不一致
+		if strings.EqualFold(event, EventScan) {
+			return &ScanEvent{}
+		}
+	}
+	return nil
+}
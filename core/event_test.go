@@ -0,0 +1,132 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+// eventXML 以固定的收发方与时间构造推送
+func eventXML(msgType, fields string) []byte {
+	return []byte("<xml><ToUserName><![CDATA[gh_1]]></ToUserName><FromUserName><![CDATA[o_user]]></FromUserName>" +
+		"<CreateTime>1600000000</CreateTime><MsgType><![CDATA[" + msgType + "]]></MsgType>" + fields + "</xml>")
+}
+
+func TestParseEventMessagePayload(t *testing.T) {
+	header := EventHeaderMessage{
+		ToUserName:   "gh_1",
+		FromUserName: "o_user",
+		CreateTime:   1600000000,
+	}
+	withType := func(msgType string) EventHeaderMessage {
+		h := header
+		h.MsgType = msgType
+		return h
+	}
+	tests := []struct {
+		name    string
+		xml     []byte
+		payload interface{}
+	}{
+		{
+			"text",
+			eventXML(MsgTypeText, "<Content><![CDATA[hello]]></Content><MsgId>1001</MsgId>"),
+			&TextMessage{EventHeaderMessage: withType(MsgTypeText), MsgId: 1001, Content: "hello"},
+		},
+		{
+			"image",
+			eventXML(MsgTypeImage, "<PicUrl><![CDATA[http://pic]]></PicUrl><MediaId><![CDATA[media]]></MediaId><MsgId>1002</MsgId>"),
+			&ImageMessage{EventHeaderMessage: withType(MsgTypeImage), MsgId: 1002, PicUrl: "http://pic", MediaId: "media"},
+		},
+		{
+			"voice with recognition",
+			eventXML(MsgTypeVoice, "<MediaId><![CDATA[media]]></MediaId><Format><![CDATA[amr]]></Format><Recognition><![CDATA[你好]]></Recognition><MsgId>1003</MsgId>"),
+			&VoiceMessage{EventHeaderMessage: withType(MsgTypeVoice), MsgId: 1003, MediaId: "media", Format: "amr", Recognition: "你好"},
+		},
+		{
+			"shortvideo",
+			eventXML(MsgTypeShortVideo, "<MediaId><![CDATA[media]]></MediaId><ThumbMediaId><![CDATA[thumb]]></ThumbMediaId><MsgId>1004</MsgId>"),
+			&VideoMessage{EventHeaderMessage: withType(MsgTypeShortVideo), MsgId: 1004, MediaId: "media", ThumbMediaId: "thumb"},
+		},
+		{
+			"location",
+			eventXML(MsgTypeLocation, "<Location_X>23.134521</Location_X><Location_Y>113.358803</Location_Y><Scale>20</Scale><Label><![CDATA[位置]]></Label><MsgId>1005</MsgId>"),
+			&LocationMessage{EventHeaderMessage: withType(MsgTypeLocation), MsgId: 1005, LocationX: 23.134521, LocationY: 113.358803, Scale: 20, Label: "位置"},
+		},
+		{
+			"subscribe with scene",
+			eventXML(MsgTypeEvent, "<Event><![CDATA[subscribe]]></Event><EventKey><![CDATA[qrscene_123]]></EventKey><Ticket><![CDATA[ticket]]></Ticket>"),
+			&SubscribeEvent{EventHeaderMessage: withType(MsgTypeEvent), Event: EventSubscribe, EventKey: "qrscene_123", Ticket: "ticket"},
+		},
+		{
+			"lower case scan",
+			eventXML(MsgTypeEvent, "<Event><![CDATA[scan]]></Event><EventKey><![CDATA[123]]></EventKey>"),
+			&ScanEvent{EventHeaderMessage: withType(MsgTypeEvent), Event: "scan", EventKey: "123"},
+		},
+		{
+			"view",
+			eventXML(MsgTypeEvent, "<Event><![CDATA[VIEW]]></Event><EventKey><![CDATA[http://url]]></EventKey><MenuId>208396938</MenuId>"),
+			&ViewEvent{EventHeaderMessage: withType(MsgTypeEvent), Event: EventView, EventKey: "http://url", MenuId: 208396938},
+		},
+		{
+			"template send job finish",
+			eventXML(MsgTypeEvent, "<Event><![CDATA[TEMPLATESENDJOBFINISH]]></Event><MsgID>200163836</MsgID><Status><![CDATA[success]]></Status>"),
+			&TemplateSendJobFinishEvent{EventHeaderMessage: withType(MsgTypeEvent), Event: EventTemplateSendJobFinish, MsgId: 200163836, Status: "success"},
+		},
+		{
+			"weapp audit fail",
+			eventXML(MsgTypeEvent, "<Event><![CDATA[weapp_audit_fail]]></Event><Reason><![CDATA[reason]]></Reason><FailTime>1488800000</FailTime><ScreenShot><![CDATA[media1|media2]]></ScreenShot>"),
+			&WeappAuditFailEvent{EventHeaderMessage: withType(MsgTypeEvent), Event: EventWeappAuditFail, Reason: "reason", FailTime: 1488800000, ScreenShot: "media1|media2"},
+		},
+		{
+			"subscribe msg popup",
+			eventXML(MsgTypeEvent, "<Event><![CDATA[subscribe_msg_popup_event]]></Event><SubscribeMsgPopupEvent>"+
+				"<List><TemplateId><![CDATA[tpl1]]></TemplateId><SubscribeStatusString><![CDATA[accept]]></SubscribeStatusString><PopupScene>2</PopupScene></List>"+
+				"<List><TemplateId><![CDATA[tpl2]]></TemplateId><SubscribeStatusString><![CDATA[reject]]></SubscribeStatusString><PopupScene>2</PopupScene></List>"+
+				"</SubscribeMsgPopupEvent>"),
+			&SubscribeMsgPopupEvent{EventHeaderMessage: withType(MsgTypeEvent), Event: EventSubscribeMsgPopup, List: []SubscribeMsgPopupItem{
+				{TemplateId: "tpl1", SubscribeStatusString: "accept", PopupScene: 2},
+				{TemplateId: "tpl2", SubscribeStatusString: "reject", PopupScene: 2},
+			}},
+		},
+		{
+			"subscribe msg sent",
+			eventXML(MsgTypeEvent, "<Event><![CDATA[subscribe_msg_sent_event]]></Event><SubscribeMsgSentEvent>"+
+				"<List><TemplateId><![CDATA[tpl1]]></TemplateId><MsgID><![CDATA[1700827132819554304]]></MsgID><ErrorCode>0</ErrorCode><ErrorStatus><![CDATA[success]]></ErrorStatus></List>"+
+				"</SubscribeMsgSentEvent>"),
+			&SubscribeMsgSentEvent{EventHeaderMessage: withType(MsgTypeEvent), Event: EventSubscribeMsgSent, List: []SubscribeMsgSentItem{
+				{TemplateId: "tpl1", MsgId: "1700827132819554304", ErrorCode: 0, ErrorStatus: "success"},
+			}},
+		},
+		{
+			"unknown event",
+			eventXML(MsgTypeEvent, "<Event><![CDATA[unknown_event]]></Event>"),
+			nil,
+		},
+		{
+			"unknown message type",
+			eventXML("unknown", ""),
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, err := ParseEventMessage(tt.xml)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(message.Payload, tt.payload) {
+				t.Fatalf("expected %#v, got %#v", tt.payload, message.Payload)
+			}
+			if message.ToUserName != "gh_1" || message.FromUserName != "o_user" || message.CreateTime != 1600000000 {
+				t.Fatalf("unexpected header %#v", message.EventHeaderMessage)
+			}
+		})
+	}
+}
+
+func TestParseEventMessageInvalid(t *testing.T) {
+	_, err := ParseEventMessage([]byte("<xml><ToUserName>"))
+	if err == nil {
+		t.Fatal("expected error for truncated xml")
+	}
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"encoding/xml"
+	"net/http"
+)
+
+// OnEvent 授权方的消息与事件推送, 按注册顺序调用, 第一个不为 nil 的返回值作为被动回复
+func (self *Server) OnEvent(handler EventReplyHandler) {
+	self.notify.event = append(self.notify.event, handler)
+}
+
+// handleEvent 调用授权方或 OnEvent 注册的处理函数
+func (self *Server) handleEvent(message *EventMessage) Reply {
+	handlers, ok := self.notify.authorizerEvent[message.AuthorizerAppId]
+	if !ok {
+		handlers = self.notify.event
+	}
+	var reply Reply
+	for _, handler := range handlers {
+		r := handler(message)
+		if reply == nil {
+			reply = r
+		}
+	}
+	return reply
+}
+
+// ServeHTTP 同一地址接收第三方平台推送与授权方消息, 按解密后是否有 InfoType 区分,
+// 使用 OnAuthorized、OnEvent 等注册的处理函数
+func (self *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if isVerifyURLRequest(r) {
+		self.VerifyURL(w, r)
+		return
+	}
+	req, ok := self.decodePush(w, r)
+	if !ok {
+		return
+	}
+	if isNotifyMessage(req.plaintext) {
+		self.serveNotify(w, r, req, nil)
+		return
+	}
+	self.serveEvent(w, r, req, self.handleEvent)
+}
+
+// HandleNotify 授权事件接收地址, 使用 OnAuthorized 等注册的处理函数
+func (self *Server) HandleNotify() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		self.Serve(w, r, nil)
+	})
+}
+
+// HandleEvent 消息与事件接收地址, 使用 OnAuthorizerEvent 与 OnEvent 注册的处理函数
+func (self *Server) HandleEvent() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		self.EventReplyServe(w, r, nil)
+	})
+}
+
+func isNotifyMessage(plaintext []byte) bool {
+	var header NotifyHeaderMessage
+	err := xml.Unmarshal(plaintext, &header)
+	return err == nil && header.InfoType != ""
+}
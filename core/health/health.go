@@ -0,0 +1,286 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/mrwangjinjin/go-wechat/core/open"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTicketMaxAge component_verify_ticket 每10分钟推送一次, 超过该时间未收到视为推送中断
+	DefaultTicketMaxAge = 30 * time.Minute
+	// DefaultMaxRefreshFailures 令牌连续刷新失败的次数达到该值时告警
+	DefaultMaxRefreshFailures = 3
+	// DefaultCheckInterval Run 的 interval 未设置时的检查间隔
+	DefaultCheckInterval = time.Minute
+)
+
+// 告警类型
+const (
+	AlertTicketStale            = "ticket_stale"
+	AlertComponentTokenFailure  = "component_token_failure"
+	AlertComponentTokenExpiring = "component_token_expiring"
+	AlertAuthorizerTokenFailure = "authorizer_token_failure"
+)
+
+// Alert 超过阈值时的告警
+type Alert struct {
+	Kind            string `json:"kind"`
+	Message         string `json:"message"`
+	AuthorizerAppId string `json:"authorizer_appid,omitempty"`
+}
+
+type AlertHandler func(alert *Alert)
+
+// TicketStatus component_verify_ticket 状态
+type TicketStatus struct {
+	Healthy    bool   `json:"healthy"`
+	ReceivedAt int64  `json:"received_at"`
+	AgeSeconds int64  `json:"age_seconds"`
+	Error      string `json:"error,omitempty"`
+}
+
+// TokenStatus component_access_token 状态
+type TokenStatus struct {
+	Healthy          bool   `json:"healthy"`
+	ExpiresAt        int64  `json:"expires_at"`
+	RemainingSeconds int64  `json:"remaining_seconds"`
+	Failures         int    `json:"failures"`
+	LastError        string `json:"last_error,omitempty"`
+}
+
+// AuthorizerStatus 授权方令牌刷新状态
+type AuthorizerStatus struct {
+	AuthorizerAppId string `json:"authorizer_appid"`
+	Healthy         bool   `json:"healthy"`
+	Failures        int    `json:"failures"`
+	LastError       string `json:"last_error,omitempty"`
+	FailedAt        int64  `json:"failed_at,omitempty"`
+}
+
+// Status 健康检查结果
+type Status struct {
+	Healthy        bool                `json:"healthy"`
+	CheckedAt      int64               `json:"checked_at"`
+	Ticket         TicketStatus        `json:"ticket"`
+	ComponentToken TokenStatus         `json:"component_token"`
+	Authorizers    []*AuthorizerStatus `json:"authorizers,omitempty"`
+	Alerts         []*Alert            `json:"alerts,omitempty"`
+}
+
+// Monitor 从缓存读取票据接收时间、component_access_token 过期时间与授权方令牌刷新失败记录
+type Monitor struct {
+	client   *open.Client
+	alerts   []AlertHandler
+	resolves []AlertHandler
+
+	mu     sync.Mutex
+	active map[string]*Alert
+
+	// Authorizers 需要检查的授权方appid, 默认读取 open.Client.ListAuthorizers, 为空时不检查授权方
+	Authorizers        func() ([]string, error)
+	TicketMaxAge       time.Duration
+	MaxRefreshFailures int
+	// ComponentTokenMinRemaining component_access_token 剩余有效期低于该值或不存在时告警, 为0时不检查.
+	// 令牌在调用接口时按需刷新, 没有后台定时调用接口时不要设置
+	ComponentTokenMinRemaining time.Duration
+}
+
+func NewMonitor(client *open.Client) *Monitor {
+	return &Monitor{
+		client:             client,
+		Authorizers:        client.ListAuthorizers,
+		TicketMaxAge:       DefaultTicketMaxAge,
+		MaxRefreshFailures: DefaultMaxRefreshFailures,
+	}
+}
+
+// OnAlert 注册告警回调, Check 发现新的异常时调用, 持续的异常不会重复调用
+func (self *Monitor) OnAlert(handler AlertHandler) {
+	self.alerts = append(self.alerts, handler)
+}
+
+// OnResolve 注册恢复回调, 之前告警的异常在 Check 中不再出现时调用
+func (self *Monitor) OnResolve(handler AlertHandler) {
+	self.resolves = append(self.resolves, handler)
+}
+
+// Summary 只读取票据与 component_access_token 的状态, 不读取授权方, 用于健康检查探针
+func (self *Monitor) Summary() *Status {
+	now := time.Now()
+	status := &Status{
+		CheckedAt: now.Unix(),
+	}
+	self.checkComponent(status, now)
+	status.Healthy = len(status.Alerts) == 0
+	return status
+}
+
+// Status 读取包括授权方在内的当前状态, 不触发告警
+func (self *Monitor) Status() *Status {
+	now := time.Now()
+	status := &Status{
+		CheckedAt: now.Unix(),
+	}
+	self.checkComponent(status, now)
+	self.checkAuthorizers(status)
+	status.Healthy = len(status.Alerts) == 0
+	return status
+}
+
+// checkComponent 读取票据与 component_access_token 的状态
+func (self *Monitor) checkComponent(status *Status, now time.Time) {
+	ticket, err := self.client.ComponentTicket()
+	if err != nil {
+		status.Ticket.Error = err.Error()
+	} else {
+		status.Ticket.ReceivedAt = ticket.ReceivedAt
+		status.Ticket.AgeSeconds = int64(ticket.Age() / time.Second)
+		status.Ticket.Healthy = ticket.ReceivedAt > 0 && ticket.Age() <= self.TicketMaxAge
+	}
+	if !status.Ticket.Healthy {
+		status.Alerts = append(status.Alerts, &Alert{
+			Kind:    AlertTicketStale,
+			Message: "component_verify_ticket 超过 " + self.TicketMaxAge.String() + " 未推送",
+		})
+	}
+
+	status.ComponentToken.Healthy = true
+	expiresAt, err := self.client.ComponentTokenExpiresAt()
+	if err == nil {
+		status.ComponentToken.ExpiresAt = expiresAt.Unix()
+		status.ComponentToken.RemainingSeconds = int64(expiresAt.Sub(now) / time.Second)
+	}
+	if self.ComponentTokenMinRemaining > 0 && (err != nil || expiresAt.Sub(now) < self.ComponentTokenMinRemaining) {
+		status.ComponentToken.Healthy = false
+		status.Alerts = append(status.Alerts, &Alert{
+			Kind:    AlertComponentTokenExpiring,
+			Message: "component_access_token 剩余有效期不足 " + self.ComponentTokenMinRemaining.String(),
+		})
+	}
+	failure, err := self.client.ComponentTokenFailure()
+	if err == nil {
+		status.ComponentToken.Failures = failure.Count
+		status.ComponentToken.LastError = failure.LastError
+		if failure.Count >= self.MaxRefreshFailures {
+			status.ComponentToken.Healthy = false
+			status.Alerts = append(status.Alerts, &Alert{
+				Kind:    AlertComponentTokenFailure,
+				Message: failure.LastError,
+			})
+		}
+	}
+}
+
+// checkAuthorizers 读取授权方令牌的刷新失败记录
+func (self *Monitor) checkAuthorizers(status *Status) {
+	if self.Authorizers == nil {
+		return
+	}
+	authorizerAppIds, err := self.Authorizers()
+	if err != nil {
+		log.Println(err)
+	}
+	for _, authorizerAppId := range authorizerAppIds {
+		authorizer := &AuthorizerStatus{
+			AuthorizerAppId: authorizerAppId,
+			Healthy:         true,
+		}
+		failure, err := self.client.AuthorizerRefreshFailure(authorizerAppId)
+		if err == nil {
+			authorizer.Failures = failure.Count
+			authorizer.LastError = failure.LastError
+			authorizer.FailedAt = failure.FailedAt
+			if failure.Count >= self.MaxRefreshFailures {
+				authorizer.Healthy = false
+				status.Alerts = append(status.Alerts, &Alert{
+					Kind:            AlertAuthorizerTokenFailure,
+					Message:         failure.LastError,
+					AuthorizerAppId: authorizerAppId,
+				})
+			}
+		}
+		status.Authorizers = append(status.Authorizers, authorizer)
+	}
+}
+
+// Check 读取当前状态, 出现新的告警时调用 OnAlert 注册的回调, 告警消失时调用 OnResolve 注册的回调
+func (self *Monitor) Check() *Status {
+	status := self.Status()
+	current := make(map[string]*Alert, len(status.Alerts))
+	var raised, resolved []*Alert
+	self.mu.Lock()
+	for _, alert := range status.Alerts {
+		key := alert.Kind + "@@" + alert.AuthorizerAppId
+		current[key] = alert
+		if _, ok := self.active[key]; !ok {
+			raised = append(raised, alert)
+		}
+	}
+	for key, alert := range self.active {
+		if _, ok := current[key]; !ok {
+			resolved = append(resolved, alert)
+		}
+	}
+	self.active = current
+	self.mu.Unlock()
+
+	for _, alert := range raised {
+		for _, handler := range self.alerts {
+			handler(alert)
+		}
+	}
+	for _, alert := range resolved {
+		for _, handler := range self.resolves {
+			handler(alert)
+		}
+	}
+	return status
+}
+
+// Run 每隔 interval 检查一次, interval 不大于0时使用 DefaultCheckInterval, ctx 取消时返回
+func (self *Monitor) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		self.Check()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ServeHTTP 以JSON返回 Summary, 票据或 component_access_token 异常时状态码为503, 可挂载为 /healthz.
+// 单个授权方的刷新失败不影响状态码, 通过 Report 查看
+func (self *Monitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status := self.Summary()
+	code := http.StatusOK
+	if !status.Healthy {
+		code = http.StatusServiceUnavailable
+	}
+	writeStatus(w, code, status)
+}
+
+// Report 以JSON返回包括授权方在内的 Status, 状态码总是200.
+// 结果包含授权方appid与错误信息, 且每次读取所有授权方的缓存, 应挂载在需要鉴权的内部路由
+func (self *Monitor) Report(w http.ResponseWriter, r *http.Request) {
+	writeStatus(w, http.StatusOK, self.Status())
+}
+
+func writeStatus(w http.ResponseWriter, code int, status *Status) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	err := json.NewEncoder(w).Encode(status)
+	if err != nil {
+		log.Println(err)
+	}
+}
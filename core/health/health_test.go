@@ -0,0 +1,157 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/mrwangjinjin/go-wechat/core"
+	"github.com/mrwangjinjin/go-wechat/core/open"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestMonitor() (*Monitor, *open.Client) {
+	client := open.NewClient(&core.ClientConfig{AppId: "wx_component"}, core.NewMemoryCache())
+	return NewMonitor(client), client
+}
+
+func TestCheckAlertsOnTransitions(t *testing.T) {
+	monitor, client := newTestMonitor()
+	var raised, resolved []string
+	monitor.OnAlert(func(alert *Alert) {
+		raised = append(raised, alert.Kind)
+	})
+	monitor.OnResolve(func(alert *Alert) {
+		resolved = append(resolved, alert.Kind)
+	})
+
+	// 没有收到票据, 持续的告警只通知一次
+	for i := 0; i < 3; i++ {
+		status := monitor.Check()
+		if status.Healthy {
+			t.Fatal("expected unhealthy without a ticket")
+		}
+	}
+	if len(raised) != 1 || raised[0] != AlertTicketStale {
+		t.Fatalf("expected a single ticket alert, got %v", raised)
+	}
+
+	err := client.Cache.Set(core.ComponentTicketCacheKeyPrefix+client.AppId, &core.ComponentTicket{
+		ComponentVerifyTicket: "ticket",
+		ReceivedAt:            time.Now().Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		status := monitor.Check()
+		if !status.Healthy {
+			t.Fatalf("expected healthy after ticket, alerts %v", status.Alerts)
+		}
+	}
+	if len(resolved) != 1 || resolved[0] != AlertTicketStale {
+		t.Fatalf("expected a single resolve, got %v", resolved)
+	}
+	if len(raised) != 1 {
+		t.Fatalf("unexpected alerts %v", raised)
+	}
+}
+
+func TestComponentTokenMinRemaining(t *testing.T) {
+	monitor, client := newTestMonitor()
+	_ = client.Cache.Set(core.ComponentTicketCacheKeyPrefix+client.AppId, &core.ComponentTicket{
+		ComponentVerifyTicket: "ticket",
+		ReceivedAt:            time.Now().Unix(),
+	})
+	setToken := func(remaining time.Duration) {
+		err := client.Cache.Set(open.ComponentTokenCacheKeyPrefix+client.AppId, map[string]interface{}{
+			"component_access_token": "token",
+			"expires_in":             time.Now().Add(remaining).Unix(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 未设置阈值时不检查
+	setToken(time.Minute)
+	if status := monitor.Status(); !status.Healthy {
+		t.Fatalf("expected healthy without threshold, alerts %v", status.Alerts)
+	}
+
+	monitor.ComponentTokenMinRemaining = 10 * time.Minute
+	status := monitor.Status()
+	if status.Healthy || status.ComponentToken.Healthy || status.Alerts[0].Kind != AlertComponentTokenExpiring {
+		t.Fatalf("expected component token alert, got %+v", status)
+	}
+
+	setToken(time.Hour)
+	if status := monitor.Status(); !status.Healthy {
+		t.Fatalf("expected healthy with enough remaining, alerts %v", status.Alerts)
+	}
+
+	_ = client.Cache.Delete(open.ComponentTokenCacheKeyPrefix + client.AppId)
+	if status := monitor.Status(); status.ComponentToken.Healthy {
+		t.Fatal("expected missing token to be unhealthy")
+	}
+}
+
+func TestRunWithoutInterval(t *testing.T) {
+	monitor, _ := newTestMonitor()
+	checks := 0
+	monitor.OnAlert(func(alert *Alert) {
+		checks++
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	monitor.Run(ctx, 0)
+	if checks != 1 {
+		t.Fatalf("expected one check before the context ended, got %d", checks)
+	}
+}
+
+func TestServeHTTPIgnoresAuthorizers(t *testing.T) {
+	monitor, client := newTestMonitor()
+	_ = client.Cache.Set(core.ComponentTicketCacheKeyPrefix+client.AppId, &core.ComponentTicket{
+		ComponentVerifyTicket: "ticket",
+		ReceivedAt:            time.Now().Unix(),
+	})
+	_ = client.Cache.Set(open.AuthorizerRefreshFailureCacheKeyPrefix+"wx_authorizer", &open.RefreshFailure{
+		Count:     DefaultMaxRefreshFailures,
+		LastError: "invalid refresh token",
+	})
+	listed := 0
+	monitor.Authorizers = func() ([]string, error) {
+		listed++
+		return []string{"wx_authorizer"}, nil
+	}
+
+	// 单个授权方刷新失败时探针仍为200, 且不读取授权方
+	w := httptest.NewRecorder()
+	monitor.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	var status Status
+	_ = json.Unmarshal(w.Body.Bytes(), &status)
+	if w.Code != http.StatusOK || !status.Healthy || len(status.Authorizers) != 0 {
+		t.Fatalf("expected healthy summary without authorizers, got %d %s", w.Code, w.Body.String())
+	}
+	if listed != 0 {
+		t.Fatalf("probe listed authorizers %d times", listed)
+	}
+
+	w = httptest.NewRecorder()
+	monitor.Report(w, httptest.NewRequest(http.MethodGet, "/healthz/report", nil))
+	status = Status{}
+	_ = json.Unmarshal(w.Body.Bytes(), &status)
+	if w.Code != http.StatusOK || status.Healthy || len(status.Authorizers) != 1 || status.Authorizers[0].Healthy {
+		t.Fatalf("expected report with the failing authorizer, got %d %s", w.Code, w.Body.String())
+	}
+
+	// 票据中断时探针返回503
+	_ = client.Cache.Delete(core.ComponentTicketCacheKeyPrefix + client.AppId)
+	w = httptest.NewRecorder()
+	monitor.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without a ticket, got %d", w.Code)
+	}
+}
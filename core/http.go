@@ -2,37 +2,69 @@ package core
 
 import (
 	"bytes"
+	"context"
 	"io/ioutil"
 	"net/http"
+	"time"
 )
 
+// DefaultHttpTimeout 默认请求超时时间
+const DefaultHttpTimeout = 30 * time.Second
+
 type HttpClient struct {
 	http *http.Client
 }
 
 func NewHttpClient() *HttpClient {
+	return NewHttpClientWithTimeout(DefaultHttpTimeout)
+}
+
+// NewHttpClientWithTimeout timeout 为0时使用 DefaultHttpTimeout
+func NewHttpClientWithTimeout(timeout time.Duration) *HttpClient {
+	if timeout <= 0 {
+		timeout = DefaultHttpTimeout
+	}
 	return &HttpClient{
-		http: &http.Client{},
+		http: &http.Client{
+			Timeout: timeout,
+		},
 	}
 }
 
+// Timeout 单次请求的超时时间
+func (self *HttpClient) Timeout() time.Duration {
+	return self.http.Timeout
+}
+
 func (self *HttpClient) Get(url string) (status int, body []byte, err error) {
-	resp, err := self.http.Get(url)
+	return self.GetCtx(context.Background(), url)
+}
+
+func (self *HttpClient) GetCtx(ctx context.Context, url string) (status int, body []byte, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return http.StatusInternalServerError, nil, err
+		return http.StatusBadRequest, nil, err
 	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
-	body, err = ioutil.ReadAll(resp.Body)
+	return self.do(req)
+}
+
+func (self *HttpClient) Post(url, contentType string, data []byte) (status int, body []byte, err error) {
+	return self.PostCtx(context.Background(), url, contentType, data)
+}
+
+func (self *HttpClient) PostCtx(ctx context.Context, url, contentType string, data []byte) (status int, body []byte, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
 	if err != nil {
 		return http.StatusBadRequest, nil, err
 	}
-	return resp.StatusCode, body, nil
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return self.do(req)
 }
 
-func (self *HttpClient) Post(url, contentType string, data []byte) (status int, body []byte, err error) {
-	resp, err := self.http.Post(url, contentType, bytes.NewReader(data))
+func (self *HttpClient) do(req *http.Request) (status int, body []byte, err error) {
+	resp, err := self.http.Do(req)
 	if err != nil {
 		return http.StatusInternalServerError, nil, err
 	}
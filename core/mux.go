@@ -0,0 +1,165 @@
+package core
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/mrwangjinjin/go-wechat/internal/util"
+	"github.com/mrwangjinjin/go-wechat/pkg/crypto"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ComponentMux 同一进程接收多个第三方平台的推送, 按路由或推送中的 AppId 选择 Server,
+// 都没有时按签名匹配 Token, 每个 Server 独立缓存 component_verify_ticket 并注册各自的处理函数
+type ComponentMux struct {
+	// Route 从请求中取第三方平台 AppId, 如 PathRoute("/notify/"), 为空或返回空串时使用推送中的 AppId
+	Route func(r *http.Request) string
+
+	mu      sync.RWMutex
+	servers map[string]*Server
+}
+
+func NewComponentMux(servers ...*Server) *ComponentMux {
+	mux := &ComponentMux{
+		servers: make(map[string]*Server),
+	}
+	for _, server := range servers {
+		mux.Handle(server)
+	}
+	return mux
+}
+
+// Handle 注册第三方平台, 相同 AppId 的 Server 会被替换
+func (self *ComponentMux) Handle(server *Server) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.servers[server.AppId] = server
+}
+
+// Server 按 AppId 查找已注册的第三方平台
+func (self *ComponentMux) Server(appId string) (*Server, bool) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	server, ok := self.servers[appId]
+	return server, ok
+}
+
+func (self *ComponentMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	appId := ""
+	if self.Route != nil {
+		appId = self.Route(r)
+	}
+	if appId == "" {
+		appId = envelopeAppId(r)
+	}
+	server, ok := self.Server(appId)
+	if !ok && appId == "" {
+		// 消息推送与地址校验不带 AppId, 按各平台的 Token 校验签名
+		server, ok = self.matchSignature(r)
+	}
+	if !ok {
+		log.Println("未注册的第三方平台:", appId)
+		http.NotFound(w, r)
+		return
+	}
+	server.ServeHTTP(w, r)
+}
+
+// matchSignature 查找 Token 能通过 signature 校验的第三方平台,
+// 多个平台使用相同的 Token 时(如正式与测试环境)按密文能否以各自的 AesKey 与 AppId 解密区分
+func (self *ComponentMux) matchSignature(r *http.Request) (*Server, bool) {
+	query := r.URL.Query()
+	signature := query.Get("signature")
+	if signature == "" {
+		return nil, false
+	}
+	self.mu.RLock()
+	var candidates []*Server
+	for _, server := range self.servers {
+		if util.SecureCompareString(signature, util.Sign(server.Token, query.Get("timestamp"), query.Get("nonce"))) {
+			candidates = append(candidates, server)
+		}
+	}
+	self.mu.RUnlock()
+	if len(candidates) <= 1 {
+		return firstServer(candidates)
+	}
+	if isVerifyURLRequest(r) && query.Get("msg_signature") == "" {
+		// 明文模式的地址校验原样返回 echostr, 任一平台的响应都相同
+		return firstServer(candidates)
+	}
+
+	ciphertext := query.Get("echostr")
+	if ciphertext == "" {
+		var msg CipherRequestHttpBody
+		err := xml.Unmarshal(peekBody(r), &msg)
+		if err == nil {
+			ciphertext = string(msg.Base64EncryptedMsg)
+		}
+	}
+	if query.Get("msg_signature") == "" || ciphertext == "" {
+		// 明文模式无法区分相同 Token 的平台
+		log.Println("多个第三方平台使用相同的 Token, 明文推送无法区分")
+		return nil, false
+	}
+	var matched []*Server
+	for _, server := range candidates {
+		crypter, err := crypto.NewMsgCrypter(server.Token, server.AesKey, server.AppId)
+		if err != nil {
+			continue
+		}
+		_, err = crypter.Decrypt(ciphertext)
+		if err == nil {
+			matched = append(matched, server)
+		}
+	}
+	if len(matched) > 1 {
+		log.Println("多个第三方平台使用相同的 Token、AesKey 与 AppId, 无法区分")
+		return nil, false
+	}
+	return firstServer(matched)
+}
+
+func firstServer(servers []*Server) (*Server, bool) {
+	if len(servers) == 0 {
+		return nil, false
+	}
+	return servers[0], true
+}
+
+// PathRoute 取 prefix 之后的第一段路径作为 AppId, 如 /notify/{appid}
+func PathRoute(prefix string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			return ""
+		}
+		return strings.SplitN(strings.TrimPrefix(r.URL.Path, prefix), "/", 2)[0]
+	}
+}
+
+// envelopeAppId 读取推送中的 AppId
+func envelopeAppId(r *http.Request) string {
+	var msg CipherRequestHttpBody
+	err := xml.Unmarshal(peekBody(r), &msg)
+	if err != nil {
+		return ""
+	}
+	return msg.AppId
+}
+
+// peekBody 读取请求体后重置以便 Server 再次读取
+func peekBody(r *http.Request) []byte {
+	if r.Body == nil {
+		return nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	_ = r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+	return body
+}
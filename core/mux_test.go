@@ -0,0 +1,136 @@
+package core
+
+import (
+	"fmt"
+	"github.com/mrwangjinjin/go-wechat/internal/util"
+	"github.com/mrwangjinjin/go-wechat/pkg/crypto"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 正式与测试环境使用相同的 Token
+func newSharedTokenServers() (*Server, *Server) {
+	prod := NewServer(&ClientConfig{
+		AppId:  "wx_prod",
+		Token:  "shared_token",
+		AesKey: "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG",
+	}, NewMemoryCache())
+	sandbox := NewServer(&ClientConfig{
+		AppId:  "wx_sandbox",
+		Token:  "shared_token",
+		AesKey: "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789abcdefg",
+	}, NewMemoryCache())
+	return prod, sandbox
+}
+
+// newEncryptedRequest 以 server 的配置加密并签名
+func newEncryptedRequest(t *testing.T, server *Server, method, nonce, plaintext string, echo bool) *http.Request {
+	crypter, err := crypto.NewMsgCrypter(server.Token, server.AesKey, server.AppId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypt, err := crypter.Encrypt([]byte(plaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	query := url.Values{
+		"timestamp":     {timestamp},
+		"nonce":         {nonce},
+		"signature":     {util.Sign(server.Token, timestamp, nonce)},
+		"msg_signature": {crypter.Sign(timestamp, nonce, encrypt)},
+	}
+	if echo {
+		query.Set("echostr", encrypt)
+		return httptest.NewRequest(method, "/?"+query.Encode(), nil)
+	}
+	query.Set("encrypt_type", "aes")
+	body := "<xml><ToUserName><![CDATA[gh_1]]></ToUserName><Encrypt><![CDATA[" + encrypt + "]]></Encrypt></xml>"
+	return httptest.NewRequest(method, "/?"+query.Encode(), strings.NewReader(body))
+}
+
+func TestComponentMuxSharedTokenRoutesByCiphertext(t *testing.T) {
+	prod, sandbox := newSharedTokenServers()
+	handled := map[string]int{}
+	for _, server := range []*Server{prod, sandbox} {
+		appId := server.AppId
+		server.OnEvent(func(message *EventMessage) Reply {
+			handled[appId]++
+			return nil
+		})
+	}
+	mux := NewComponentMux(prod, sandbox)
+
+	// map 遍历顺序随机, 多次请求确保不依赖顺序
+	for i := 0; i < 20; i++ {
+		plaintext := fmt.Sprintf("<xml><ToUserName><![CDATA[gh_1]]></ToUserName><FromUserName><![CDATA[o_user]]></FromUserName>"+
+			"<CreateTime>1600000000</CreateTime><MsgType><![CDATA[text]]></MsgType><Content><![CDATA[hi]]></Content><MsgId>%d</MsgId></xml>", i+1)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newEncryptedRequest(t, sandbox, http.MethodPost, "nonce"+strconv.Itoa(i), plaintext, false))
+		if w.Code != http.StatusOK {
+			t.Fatalf("push %d: status %d", i, w.Code)
+		}
+	}
+	if handled["wx_sandbox"] != 20 || handled["wx_prod"] != 0 {
+		t.Fatalf("pushes routed to the wrong server: %v", handled)
+	}
+}
+
+func TestComponentMuxSharedTokenVerifyURL(t *testing.T) {
+	prod, sandbox := newSharedTokenServers()
+	mux := NewComponentMux(prod, sandbox)
+	for _, server := range []*Server{prod, sandbox} {
+		for i := 0; i < 10; i++ {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, newEncryptedRequest(t, server, http.MethodGet, "nonce", "echo_"+server.AppId, true))
+			if w.Code != http.StatusOK || w.Body.String() != "echo_"+server.AppId {
+				t.Fatalf("%s: status=%d body=%q", server.AppId, w.Code, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestComponentMuxSharedTokenPlaintext(t *testing.T) {
+	prod, sandbox := newSharedTokenServers()
+	mux := NewComponentMux(prod, sandbox)
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		status  int
+		body    string
+	}{
+		{
+			name: "echostr",
+			request: func() *http.Request {
+				return newPlainEcho("shared_token", "echo_plain")
+			},
+			status: http.StatusOK,
+			body:   "echo_plain",
+		},
+		{
+			// 明文推送无法区分平台
+			name: "push",
+			request: func() *http.Request {
+				return newPlainPush(prod, "<xml><ToUserName><![CDATA[gh_1]]></ToUserName></xml>")
+			},
+			status: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, tt.request())
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, w.Code)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Fatalf("expected %q, got %q", tt.body, w.Body.String())
+			}
+		})
+	}
+}
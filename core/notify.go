@@ -0,0 +1,165 @@
+package core
+
+// TicketEvent component_verify_ticket 推送
+type TicketEvent struct {
+	AppId                 string
+	CreateTime            int64
+	ComponentVerifyTicket string
+}
+
+// AuthorizedEvent 授权成功
+type AuthorizedEvent struct {
+	AppId                        string
+	CreateTime                   int64
+	AuthorizerAppid              string
+	AuthorizationCode            string
+	AuthorizationCodeExpiredTime int64
+	PreAuthCode                  string
+}
+
+// UpdateAuthorizedEvent 授权更新
+type UpdateAuthorizedEvent struct {
+	AppId                        string
+	CreateTime                   int64
+	AuthorizerAppid              string
+	AuthorizationCode            string
+	AuthorizationCodeExpiredTime int64
+	PreAuthCode                  string
+}
+
+// UnauthorizedEvent 取消授权
+type UnauthorizedEvent struct {
+	AppId           string
+	CreateTime      int64
+	AuthorizerAppid string
+}
+
+// FastRegisterEvent 快速注册小程序结果, Status 为0时注册成功
+type FastRegisterEvent struct {
+	AppId      string
+	CreateTime int64
+	Appid      string // 新创建小程序的appid
+	Status     int
+	AuthCode   string
+	Msg        string
+	Info       InfoMessage
+}
+
+type TicketHandler func(event *TicketEvent)
+type AuthorizedHandler func(event *AuthorizedEvent)
+type UpdateAuthorizedHandler func(event *UpdateAuthorizedEvent)
+type UnauthorizedHandler func(event *UnauthorizedEvent)
+type FastRegisterHandler func(event *FastRegisterEvent)
+
+// notifyRouter 第三方平台推送的处理函数, 同一类型可注册多个, 按注册顺序调用
+type notifyRouter struct {
+	ticket           []TicketHandler
+	authorized       []AuthorizedHandler
+	updateAuthorized []UpdateAuthorizedHandler
+	unauthorized     []UnauthorizedHandler
+	fastRegister     []FastRegisterHandler
+	fallback         []EventNotifyHandler
+	event            []EventReplyHandler
+	authorizerEvent  map[string][]EventReplyHandler
+
+	autoTestQueryAuth []AutoTestQueryAuthHandler
+}
+
+// OnTicket component_verify_ticket 推送, 在内置的票据缓存之后调用
+func (self *Server) OnTicket(handler TicketHandler) {
+	self.notify.ticket = append(self.notify.ticket, handler)
+}
+
+// OnAuthorized 授权成功
+func (self *Server) OnAuthorized(handler AuthorizedHandler) {
+	self.notify.authorized = append(self.notify.authorized, handler)
+}
+
+// OnUpdateAuthorized 授权更新
+func (self *Server) OnUpdateAuthorized(handler UpdateAuthorizedHandler) {
+	self.notify.updateAuthorized = append(self.notify.updateAuthorized, handler)
+}
+
+// OnUnauthorized 取消授权
+func (self *Server) OnUnauthorized(handler UnauthorizedHandler) {
+	self.notify.unauthorized = append(self.notify.unauthorized, handler)
+}
+
+// OnFastRegister 快速注册小程序结果
+func (self *Server) OnFastRegister(handler FastRegisterHandler) {
+	self.notify.fastRegister = append(self.notify.fastRegister, handler)
+}
+
+// OnNotify 未注册处理函数的推送类型
+func (self *Server) OnNotify(handler EventNotifyHandler) {
+	self.notify.fallback = append(self.notify.fallback, handler)
+}
+
+// dispatchNotify 按 InfoType 分发推送, eventHandler 不为空时作为未注册类型的处理函数
+func (self *Server) dispatchNotify(msg *NotifyMessage, eventHandler EventNotifyHandler) {
+	switch msg.InfoType {
+	case EventComponentVerifyTicket:
+		self.saveComponentTicket(msg)
+		event := &TicketEvent{
+			AppId:                 msg.AppId,
+			CreateTime:            msg.CreateTime,
+			ComponentVerifyTicket: msg.ComponentVerifyTicket,
+		}
+		for _, handler := range self.notify.ticket {
+			handler(event)
+		}
+	case EventAuthorized:
+		event := &AuthorizedEvent{
+			AppId:                        msg.AppId,
+			CreateTime:                   msg.CreateTime,
+			AuthorizerAppid:              msg.AuthorizerAppid,
+			AuthorizationCode:            msg.AuthorizationCode,
+			AuthorizationCodeExpiredTime: msg.AuthorizationCodeExpiredTime,
+			PreAuthCode:                  msg.PreAuthCode,
+		}
+		for _, handler := range self.notify.authorized {
+			handler(event)
+		}
+	case EventUpdateAuthorized:
+		event := &UpdateAuthorizedEvent{
+			AppId:                        msg.AppId,
+			CreateTime:                   msg.CreateTime,
+			AuthorizerAppid:              msg.AuthorizerAppid,
+			AuthorizationCode:            msg.AuthorizationCode,
+			AuthorizationCodeExpiredTime: msg.AuthorizationCodeExpiredTime,
+			PreAuthCode:                  msg.PreAuthCode,
+		}
+		for _, handler := range self.notify.updateAuthorized {
+			handler(event)
+		}
+	case EventUnauthorized:
+		event := &UnauthorizedEvent{
+			AppId:           msg.AppId,
+			CreateTime:      msg.CreateTime,
+			AuthorizerAppid: msg.AuthorizerAppid,
+		}
+		for _, handler := range self.notify.unauthorized {
+			handler(event)
+		}
+	case EventNotifyThirdFasteregister:
+		event := &FastRegisterEvent{
+			AppId:      msg.AppId,
+			CreateTime: msg.CreateTime,
+			Appid:      msg.Appid,
+			Status:     msg.Status,
+			AuthCode:   msg.AuthCode,
+			Msg:        msg.Msg,
+			Info:       msg.Info,
+		}
+		for _, handler := range self.notify.fastRegister {
+			handler(event)
+		}
+	default:
+		for _, handler := range self.notify.fallback {
+			handler(msg)
+		}
+		if eventHandler != nil {
+			eventHandler(msg)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package open
+
+import (
+	"context"
+	"github.com/mrwangjinjin/go-wechat/core"
+)
+
+// Authorizer 以授权方appid调用接口, 令牌由 AuthorizerTokenSource 自动获取和刷新
+type Authorizer struct {
+	client *Client
+	source *AuthorizerTokenSource
+	AppId  string
+}
+
+// Authorizer 获取授权方接口
+func (self *Client) Authorizer(authorizerAppId string) *Authorizer {
+	return &Authorizer{
+		client: self,
+		source: self.AuthorizerTokenSource(authorizerAppId),
+		AppId:  authorizerAppId,
+	}
+}
+
+// Token 获取有效的 authorizer_access_token
+func (self *Authorizer) Token(ctx context.Context) (string, error) {
+	return self.source.TokenCtx(ctx)
+}
+
+// do 使用令牌调用接口, 令牌失效时刷新后重试一次
+func (self *Authorizer) do(ctx context.Context, call func(token string) error) error {
+	token, err := self.source.TokenCtx(ctx)
+	if err != nil {
+		return err
+	}
+	err = call(token)
+	if !core.IsTokenExpired(err) {
+		return err
+	}
+	token, err = self.source.Refresh(ctx)
+	if err != nil {
+		return err
+	}
+	return call(token)
+}
+
+// BindTester 绑定体验者账号
+func (self *Authorizer) BindTester(wechatId string) (*BindTesterResponse, error) {
+	return self.BindTesterCtx(context.Background(), wechatId)
+}
+
+// BindTesterCtx 绑定体验者账号
+func (self *Authorizer) BindTesterCtx(ctx context.Context, wechatId string) (resp *BindTesterResponse, err error) {
+	err = self.do(ctx, func(token string) error {
+		resp, err = self.client.BindTesterCtx(ctx, token, wechatId)
+		return err
+	})
+	return resp, err
+}
+
+// UnbindTester 解除绑定体验者账号
+func (self *Authorizer) UnbindTester(wechatId string) error {
+	return self.UnbindTesterCtx(context.Background(), wechatId)
+}
+
+// UnbindTesterCtx 解除绑定体验者账号
+func (self *Authorizer) UnbindTesterCtx(ctx context.Context, wechatId string) error {
+	return self.do(ctx, func(token string) error {
+		return self.client.UnbindTesterCtx(ctx, token, wechatId)
+	})
+}
+
+// UnbindTesterByUserStr 使用绑定时返回的 userstr 解除绑定体验者账号
+func (self *Authorizer) UnbindTesterByUserStr(userStr string) error {
+	return self.UnbindTesterByUserStrCtx(context.Background(), userStr)
+}
+
+// UnbindTesterByUserStrCtx 使用绑定时返回的 userstr 解除绑定体验者账号
+func (self *Authorizer) UnbindTesterByUserStrCtx(ctx context.Context, userStr string) error {
+	return self.do(ctx, func(token string) error {
+		return self.client.UnbindTesterByUserStrCtx(ctx, token, userStr)
+	})
+}
+
+// ModifyServerDomain 修改小程序服务器域名
+func (self *Authorizer) ModifyServerDomain(req *ModifyDomainRequest) (*ModifyDomainResponse, error) {
+	return self.ModifyServerDomainCtx(context.Background(), req)
+}
+
+// ModifyServerDomainCtx 修改小程序服务器域名
+func (self *Authorizer) ModifyServerDomainCtx(ctx context.Context, req *ModifyDomainRequest) (resp *ModifyDomainResponse, err error) {
+	err = self.do(ctx, func(token string) error {
+		resp, err = self.client.ModifyServerDomainCtx(ctx, token, req)
+		return err
+	})
+	return resp, err
+}
+
+// Commit 上传小程序代码
+func (self *Authorizer) Commit(req *CommitCodeRequest) error {
+	return self.CommitCtx(context.Background(), req)
+}
+
+// CommitCtx 上传小程序代码
+func (self *Authorizer) CommitCtx(ctx context.Context, req *CommitCodeRequest) error {
+	return self.do(ctx, func(token string) error {
+		return self.client.CommitCtx(ctx, token, req)
+	})
+}
+
+// SubmitCodeAudit 提交审核
+func (self *Authorizer) SubmitCodeAudit(req *SubmitAuditRequest) (*SubmitAuditResponse, error) {
+	return self.SubmitCodeAuditCtx(context.Background(), req)
+}
+
+// SubmitCodeAuditCtx 提交审核
+func (self *Authorizer) SubmitCodeAuditCtx(ctx context.Context, req *SubmitAuditRequest) (resp *SubmitAuditResponse, err error) {
+	err = self.do(ctx, func(token string) error {
+		resp, err = self.client.SubmitCodeAuditCtx(ctx, token, req)
+		return err
+	})
+	return resp, err
+}
+
+// UndoAudit 审核撤回
+func (self *Authorizer) UndoAudit() error {
+	return self.UndoAuditCtx(context.Background())
+}
+
+// UndoAuditCtx 审核撤回
+func (self *Authorizer) UndoAuditCtx(ctx context.Context) error {
+	return self.do(ctx, func(token string) error {
+		return self.client.UndoAuditCtx(ctx, token)
+	})
+}
+
+// ReleaseCode 小程序发布
+func (self *Authorizer) ReleaseCode() error {
+	return self.ReleaseCodeCtx(context.Background())
+}
+
+// ReleaseCodeCtx 小程序发布
+func (self *Authorizer) ReleaseCodeCtx(ctx context.Context) error {
+	return self.do(ctx, func(token string) error {
+		return self.client.ReleaseCodeCtx(ctx, token)
+	})
+}
+
+// CreateWxaCode 小程序码
+func (self *Authorizer) CreateWxaCode(req *GetWxaCodeRequest) ([]byte, error) {
+	return self.CreateWxaCodeCtx(context.Background(), req)
+}
+
+// CreateWxaCodeCtx 小程序码
+func (self *Authorizer) CreateWxaCodeCtx(ctx context.Context, req *GetWxaCodeRequest) (body []byte, err error) {
+	err = self.do(ctx, func(token string) error {
+		body, err = self.client.CreateWxaCodeCtx(ctx, token, req)
+		return err
+	})
+	return body, err
+}
+
+// LatestAuditStatus 获取小程序最后一次审核状态
+func (self *Authorizer) LatestAuditStatus() (*AuditStatus, error) {
+	return self.LatestAuditStatusCtx(context.Background())
+}
+
+// LatestAuditStatusCtx 获取小程序最后一次审核状态
+func (self *Authorizer) LatestAuditStatusCtx(ctx context.Context) (resp *AuditStatus, err error) {
+	err = self.do(ctx, func(token string) error {
+		resp, err = self.client.LatestAuditStatusCtx(ctx, token)
+		return err
+	})
+	return resp, err
+}
+
+// ListPages 获取已上传的代码的页面列表
+func (self *Authorizer) ListPages() (*PageList, error) {
+	return self.ListPagesCtx(context.Background())
+}
+
+// ListPagesCtx 获取已上传的代码的页面列表
+func (self *Authorizer) ListPagesCtx(ctx context.Context) (resp *PageList, err error) {
+	err = self.do(ctx, func(token string) error {
+		resp, err = self.client.ListPagesCtx(ctx, token)
+		return err
+	})
+	return resp, err
+}
+
+// GetQrCode 小程序体验码, path 为空时使用默认首页
+func (self *Authorizer) GetQrCode(path string) ([]byte, error) {
+	return self.GetQrCodeCtx(context.Background(), path)
+}
+
+// GetQrCodeCtx 小程序体验码, path 为空时使用默认首页
+func (self *Authorizer) GetQrCodeCtx(ctx context.Context, path string) (body []byte, err error) {
+	err = self.do(ctx, func(token string) error {
+		if path == "" {
+			body, err = self.client.GetQrCodeWithoutPathCtx(ctx, token)
+		} else {
+			body, err = self.client.GetQrCodeCtx(ctx, token, path)
+		}
+		return err
+	})
+	return body, err
+}
+
+// GetWxaQrCode 生成带参数小程序码
+func (self *Authorizer) GetWxaQrCode(path string, width int) ([]byte, error) {
+	return self.GetWxaQrCodeCtx(context.Background(), path, width)
+}
+
+// GetWxaQrCodeCtx 生成带参数小程序码
+func (self *Authorizer) GetWxaQrCodeCtx(ctx context.Context, path string, width int) (body []byte, err error) {
+	err = self.do(ctx, func(token string) error {
+		body, err = self.client.GetWxaQrCodeCtx(ctx, token, path, width)
+		return err
+	})
+	return body, err
+}
+
+// ListTesters 获取小程序所有已绑定的体验者列表
+func (self *Authorizer) ListTesters() (*TesterList, error) {
+	return self.ListTestersCtx(context.Background())
+}
+
+// ListTestersCtx 获取小程序所有已绑定的体验者列表
+func (self *Authorizer) ListTestersCtx(ctx context.Context) (resp *TesterList, err error) {
+	err = self.do(ctx, func(token string) error {
+		resp, err = self.client.ListTestersCtx(ctx, token)
+		return err
+	})
+	return resp, err
+}
+
+// SendCustomMessage 发送客服消息
+func (self *Authorizer) SendCustomMessage(msg *CustomMessage) error {
+	return self.SendCustomMessageCtx(context.Background(), msg)
+}
+
+// SendCustomMessageCtx 发送客服消息
+func (self *Authorizer) SendCustomMessageCtx(ctx context.Context, msg *CustomMessage) error {
+	return self.do(ctx, func(token string) error {
+		return self.client.SendCustomMessageCtx(ctx, token, msg)
+	})
+}
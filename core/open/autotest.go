@@ -0,0 +1,42 @@
+package open
+
+import (
+	"context"
+	"github.com/mrwangjinjin/go-wechat/core"
+	"log"
+)
+
+// AutoTest 全网发布检测, 收到 QUERY_AUTH_CODE 时换取授权方令牌并以客服消息回复
+type AutoTest struct {
+	client *Client
+}
+
+func NewAutoTest(client *Client) *AutoTest {
+	return &AutoTest{
+		client: client,
+	}
+}
+
+// Attach 注册到 core.Server, 文本消息与事件的检测由 core.Server 直接回复
+func (self *AutoTest) Attach(server *core.Server) {
+	server.OnAutoTestQueryAuth(self.queryAuth)
+}
+
+// queryAuth 检测用的测试帐号令牌只用于本次回复, 不缓存也不登记到授权方列表与 Store
+func (self *AutoTest) queryAuth(authCode string, message *core.EventMessage) {
+	info, err := self.client.exchangeAuthCode(context.Background(), authCode)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	err = self.client.SendCustomMessage(info.AuthorizerAccessToken, &CustomMessage{
+		ToUser:  message.FromUserName,
+		MsgType: "text",
+		Text: &CustomText{
+			Content: authCode + "_from_api",
+		},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,44 @@
+package open
+
+import (
+	"context"
+	"github.com/mrwangjinjin/go-wechat/core"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAutoTestQueryAuthDoesNotPersistAuthorizer(t *testing.T) {
+	var sent int32
+	clients, cleanup := newTestClients(1, func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "query_auth") {
+			_, _ = w.Write([]byte(`{"authorization_info":{"authorizer_appid":"` + core.AutoTestMpId + `","authorizer_access_token":"access","expires_in":7200,"authorizer_refresh_token":"refresh"}}`))
+			return
+		}
+		atomic.AddInt32(&sent, 1)
+		_, _ = w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	})
+	defer cleanup()
+	client := clients[0]
+	client.Store = NewMemoryAuthorizerStore()
+
+	NewAutoTest(client).queryAuth("queryauthcode@@@code", &core.EventMessage{})
+	if sent != 1 {
+		t.Fatalf("expected 1 custom message, got %d", sent)
+	}
+	appids, err := client.ListAuthorizers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(appids) != 0 {
+		t.Fatalf("test account added to authorizer list: %v", appids)
+	}
+	records, err := client.Store.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("test account saved to store: %v", records)
+	}
+}
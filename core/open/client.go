@@ -1,6 +1,7 @@
 package open
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 )
 
@@ -17,8 +19,20 @@ const (
 	ComponentTokenCacheKeyPrefix    = "CACHE_COMPONENT@@"
 	AuthorizerTokenCacheKeyPrefix   = "CACHE_AUTHORIZER_TOKEN@@"
 	MpAuthorizerTokenCacheKeyPrefix = "CACHE_AUTHORIZER_TOKEN_MP@@"
+	// 刷新令牌不设置过期时间, 避免令牌缓存过期后需要重新授权
+	AuthorizerRefreshTokenCacheKeyPrefix = "CACHE_AUTHORIZER_REFRESH_TOKEN@@"
+	ComponentTokenLockCacheKeyPrefix     = "CACHE_COMPONENT_LOCK@@"
 )
 
+const (
+	// ComponentTokenLockTimeout 刷新component_access_token的分布式锁的最短有效期,
+	// 实际有效期不短于两次请求的超时时间, 等待锁超过有效期时返回 ErrComponentTokenLockTimeout
+	ComponentTokenLockTimeout       = 10 * time.Second
+	componentTokenLockRetryInterval = 100 * time.Millisecond
+)
+
+var ErrComponentTokenLockTimeout = errors.New("等待其他实例刷新component_access_token超时")
+
 type Client struct {
 	Http      *core.HttpClient
 	Endpoint  *core.Endpoint
@@ -27,24 +41,40 @@ type Client struct {
 	AppSecret string
 	Token     string
 	AesKey    string
+	// DistributedLock 多实例部署时通过缓存锁刷新component_access_token
+	DistributedLock bool
+	// Store 授权方的持久存储, 不为空时刷新令牌同时写入, 缓存中没有时从中读取, 授权方列表也从中读取
+	Store AuthorizerStore
+
+	componentTokenMu   sync.Mutex
+	componentTokenCall *componentTokenCall
+	tokenSourcesMu     sync.Mutex
+	tokenSources       map[string]*AuthorizerTokenSource
 }
 
 // NewClient
 func NewClient(clientConfig *core.ClientConfig, cache core.Cache) *Client {
 	return &Client{
-		Http:      core.NewHttpClient(),
-		Cache:     cache,
+		Http:      core.NewHttpClientWithTimeout(clientConfig.Timeout),
+		Cache:     core.WithNamespace(cache, clientConfig.Namespace),
 		Endpoint:  core.NewEndpoint(clientConfig.BaseUrl),
 		AppId:     clientConfig.AppId,
 		AppSecret: clientConfig.AppSecret,
 		Token:     clientConfig.Token,
 		AesKey:    clientConfig.AesKey,
+
+		DistributedLock: clientConfig.DistributedLock,
 	}
 }
 
 // GetAuthUrl 获取授权页网址
 func (self *Client) GetAuthUrl(redirectUri string, authType uint8) string {
-	preAuthCode, err := self.ApiCreatePreAuthCode()
+	return self.GetAuthUrlCtx(context.Background(), redirectUri, authType)
+}
+
+// GetAuthUrlCtx 获取授权页网址
+func (self *Client) GetAuthUrlCtx(ctx context.Context, redirectUri string, authType uint8) string {
+	preAuthCode, err := self.ApiCreatePreAuthCodeCtx(ctx)
 	if err != nil {
 		return ""
 	}
@@ -55,7 +85,24 @@ func (self *Client) GetAuthUrl(redirectUri string, authType uint8) string {
 		authType)
 }
 
+// GetAuthorizerToken 读取缓存的授权方令牌, ExpiresIn 为剩余有效秒数
+func (self *Client) GetAuthorizerToken(authorizerAppId string) (*AuthorizerToken, error) {
+	var cached authorizerTokenCache
+	err := self.getCache(AuthorizerTokenCacheKeyPrefix+authorizerAppId, &cached)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &AuthorizerToken{
+		AuthorizerAccessToken:  cached.AuthorizerAccessToken,
+		AuthorizerRefreshToken: cached.AuthorizerRefreshToken,
+		ExpiresIn:              cached.ExpiresIn - time.Now().Unix(),
+	}, nil
+}
+
 // GetToken
+//
+// Deprecated: 使用 GetAuthorizerToken
 func (self *Client) GetToken(authorizerAppId string) (map[string]interface{}, error) {
 	resp, err := self.Cache.Get(AuthorizerTokenCacheKeyPrefix + authorizerAppId)
 	if err != nil {
@@ -65,543 +112,726 @@ func (self *Client) GetToken(authorizerAppId string) (map[string]interface{}, er
 	return util.JsonUnmarshal(string(resp)), nil
 }
 
-// RefreshToken
-func (self *Client) RefreshToken(authorizerAppId, refreshToken string) (map[string]interface{}, error) {
-	dst, err := json.Marshal(map[string]interface{}{
-		"component_appid":          self.AppId,
-		"authorizer_appid":         authorizerAppId,
-		"authorizer_refresh_token": refreshToken,
-	})
-	token, err := self.ApiComponentToken()
+// RefreshAuthorizerToken 使用刷新令牌获取授权方令牌
+func (self *Client) RefreshAuthorizerToken(authorizerAppId, refreshToken string) (*AuthorizerToken, error) {
+	return self.RefreshAuthorizerTokenCtx(context.Background(), authorizerAppId, refreshToken)
+}
+
+// RefreshAuthorizerTokenCtx 使用刷新令牌获取授权方令牌
+func (self *Client) RefreshAuthorizerTokenCtx(ctx context.Context, authorizerAppId, refreshToken string) (*AuthorizerToken, error) {
+	resp, err := self.requestAuthorizerToken(ctx, authorizerAppId, refreshToken)
+	self.recordRefreshFailure(AuthorizerRefreshFailureCacheKeyPrefix+authorizerAppId, err)
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
-	status, body, err := self.Http.Post(self.Endpoint.ApiAuthorizerToken(token), "application/json", dst)
+	expires := authorizerTokenExpires(resp.ExpiresIn)
+	_ = self.Cache.SetEx(AuthorizerTokenCacheKeyPrefix+authorizerAppId, &authorizerTokenCache{
+		AuthorizerAppid:        authorizerAppId,
+		AuthorizerAccessToken:  resp.AuthorizerAccessToken,
+		AuthorizerRefreshToken: resp.AuthorizerRefreshToken,
+		ExpiresIn:              time.Now().Unix() + expires,
+	}, expires)
+	self.saveRefreshToken(authorizerAppId, resp.AuthorizerRefreshToken)
+	return resp, nil
+}
+
+func (self *Client) requestAuthorizerToken(ctx context.Context, authorizerAppId, refreshToken string) (*AuthorizerToken, error) {
+	token, err := self.ApiComponentTokenCtx(ctx)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
-	if status != http.StatusOK {
-		return nil, errors.New("网络错误")
+	var resp AuthorizerToken
+	err = self.postJSON(ctx, self.Endpoint.ApiAuthorizerToken(token), &AuthorizerTokenRequest{
+		ComponentAppid:         self.AppId,
+		AuthorizerAppid:        authorizerAppId,
+		AuthorizerRefreshToken: refreshToken,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// RefreshToken
+//
+// Deprecated: 使用 RefreshAuthorizerToken
+func (self *Client) RefreshToken(authorizerAppId, refreshToken string) (map[string]interface{}, error) {
+	resp, err := self.RefreshAuthorizerToken(authorizerAppId, refreshToken)
+	if err != nil {
+		return nil, err
 	}
-	authorizerRefreshToken := util.JsonUnmarshalBytes(body)
-	_ = self.Cache.SetEx(AuthorizerTokenCacheKeyPrefix+authorizerAppId, map[string]interface{}{
-		"authorizer_access_token":  authorizerRefreshToken["authorizer_access_token"],
-		"authorizer_refresh_token": authorizerRefreshToken["authorizer_refresh_token"],
-		"expires_in":               time.Now().Unix() + 6600,
-	}, 6600)
-	return authorizerRefreshToken, nil
+	return toMap(resp), nil
 }
 
 // ApiCreatePreAuthCode 获取预授权码
 func (self *Client) ApiCreatePreAuthCode() (string, error) {
-	dst, err := json.Marshal(map[string]interface{}{
-		"component_appid": self.AppId,
-	})
-	token, err := self.ApiComponentToken()
+	return self.ApiCreatePreAuthCodeCtx(context.Background())
+}
+
+// ApiCreatePreAuthCodeCtx 获取预授权码
+func (self *Client) ApiCreatePreAuthCodeCtx(ctx context.Context) (string, error) {
+	token, err := self.ApiComponentTokenCtx(ctx)
 	if err != nil {
 		log.Println(err)
 		return "", err
 	}
-	status, body, err := self.Http.Post(self.Endpoint.PreAuthCodoUrl(token), "application/json", dst)
+	var resp PreAuthCode
+	err = self.postJSON(ctx, self.Endpoint.PreAuthCodoUrl(token), &PreAuthCodeRequest{
+		ComponentAppid: self.AppId,
+	}, &resp)
 	if err != nil {
-		log.Println(err)
 		return "", err
 	}
-	if status != http.StatusOK {
-		return "", errors.New("网络错误")
-	}
-	resp := util.JsonUnmarshalBytes(body)
+	return resp.PreAuthCode, nil
+}
 
-	return resp["pre_auth_code"].(string), nil
+// QueryAuth 使用授权码换取公众号或小程序的接口调用凭据和授权信息
+func (self *Client) QueryAuth(code string) (*AuthorizationInfo, error) {
+	return self.QueryAuthCtx(context.Background(), code)
 }
 
-// ApiQueryAuth 使用授权码换取公众号或小程序的接口调用凭据和授权信息
-func (self *Client) ApiQueryAuth(code string) (map[string]interface{}, error) {
-	authorizerToken, err := self.getRawApiQueryAuth(code)
+// QueryAuthCtx 使用授权码换取公众号或小程序的接口调用凭据和授权信息
+func (self *Client) QueryAuthCtx(ctx context.Context, code string) (*AuthorizationInfo, error) {
+	resp, err := self.exchangeAuthCode(ctx, code)
 	if err != nil {
-		log.Println(err)
-		return authorizerToken, err
+		return nil, err
+	}
+	info := *resp
+	expires := authorizerTokenExpires(info.ExpiresIn)
+	err = self.Cache.SetEx(AuthorizerTokenCacheKeyPrefix+info.AuthorizerAppid, &authorizerTokenCache{
+		AuthorizerAppid:        info.AuthorizerAppid,
+		AuthorizerAccessToken:  info.AuthorizerAccessToken,
+		AuthorizerRefreshToken: info.AuthorizerRefreshToken,
+		ExpiresIn:              time.Now().Unix() + expires,
+		FuncInfo:               info.FuncInfo,
+	}, expires)
+	if err != nil {
+		return nil, err
 	}
-	return authorizerToken, nil
+	self.saveRefreshToken(info.AuthorizerAppid, info.AuthorizerRefreshToken)
+	self.updateStoredAuthorizer(ctx, info.AuthorizerAppid, func(record *AuthorizerRecord) {
+		record.RefreshToken = info.AuthorizerRefreshToken
+		record.FuncInfo = info.FuncInfo
+	})
+	return &info, nil
 }
 
-// ApiQueryAuth 使用授权码换取公众号或小程序的接口调用凭据和授权信息
-func (self *Client) getRawApiQueryAuth(code string) (map[string]interface{}, error) {
-	dst, err := json.Marshal(map[string]interface{}{
-		"component_appid":    self.AppId,
-		"authorization_code": code,
-	})
-	token, err := self.ApiComponentToken()
+// exchangeAuthCode 使用授权码换取授权信息, 不缓存令牌也不登记授权方
+func (self *Client) exchangeAuthCode(ctx context.Context, code string) (*AuthorizationInfo, error) {
+	token, err := self.ApiComponentTokenCtx(ctx)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
-	status, body, err := self.Http.Post(self.Endpoint.ApiQueryAuth(token), "application/json", dst)
+	var resp QueryAuthResponse
+	err = self.postJSON(ctx, self.Endpoint.ApiQueryAuth(token), &QueryAuthRequest{
+		ComponentAppid:    self.AppId,
+		AuthorizationCode: code,
+	}, &resp)
 	if err != nil {
 		return nil, err
 	}
-	if status != http.StatusOK {
-		return nil, errors.New("网络错误")
-	}
-	authorizerToken := util.JsonUnmarshalBytes(body)
-	authorzationInfo := authorizerToken["authorization_info"].(map[string]interface{})
-	authorzationInfo["expires_in"] = time.Now().Unix() + 6600
-	err = self.Cache.SetEx(AuthorizerTokenCacheKeyPrefix+authorzationInfo["authorizer_appid"].(string), authorzationInfo, 6600)
+	return &resp.AuthorizationInfo, nil
+}
+
+// ApiQueryAuth 使用授权码换取公众号或小程序的接口调用凭据和授权信息
+//
+// Deprecated: 使用 QueryAuth
+func (self *Client) ApiQueryAuth(code string) (map[string]interface{}, error) {
+	info, err := self.QueryAuth(code)
 	if err != nil {
+		log.Println(err)
 		return nil, err
 	}
-	return authorzationInfo, nil
+	// 与缓存一致, expires_in 为过期的时间戳
+	resp := toMap(info)
+	resp["expires_in"] = time.Now().Unix() + authorizerTokenExpires(info.ExpiresIn)
+	return resp, nil
 }
 
-// ApiAuthorizerInfo 获取授权方的帐号基本信息
-func (self *Client) ApiAuthorizerInfo(authorizerAppId string) (map[string]interface{}, error) {
-	dst, err := json.Marshal(map[string]interface{}{
-		"component_appid":  self.AppId,
-		"authorizer_appid": authorizerAppId,
-	})
-	token, err := self.ApiComponentToken()
+// GetAuthorizerInfo 获取授权方的帐号基本信息
+func (self *Client) GetAuthorizerInfo(authorizerAppId string) (*AuthorizerInfoResponse, error) {
+	return self.GetAuthorizerInfoCtx(context.Background(), authorizerAppId)
+}
+
+// GetAuthorizerInfoCtx 获取授权方的帐号基本信息
+func (self *Client) GetAuthorizerInfoCtx(ctx context.Context, authorizerAppId string) (*AuthorizerInfoResponse, error) {
+	token, err := self.ApiComponentTokenCtx(ctx)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
-	status, body, err := self.Http.Post(self.Endpoint.ApiAuthorizerInfo(token), "application/json", dst)
+	var resp AuthorizerInfoResponse
+	err = self.postJSON(ctx, self.Endpoint.ApiAuthorizerInfo(token), &AuthorizerInfoRequest{
+		ComponentAppid:  self.AppId,
+		AuthorizerAppid: authorizerAppId,
+	}, &resp)
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
-	if status != http.StatusOK {
-		return nil, errors.New("网络错误")
+	return &resp, nil
+}
+
+// ApiAuthorizerInfo 获取授权方的帐号基本信息
+//
+// Deprecated: 使用 GetAuthorizerInfo
+func (self *Client) ApiAuthorizerInfo(authorizerAppId string) (map[string]interface{}, error) {
+	resp, err := self.GetAuthorizerInfo(authorizerAppId)
+	if err != nil {
+		return nil, err
 	}
-	authorizerToken := util.JsonUnmarshalBytes(body)
-	authorizerInfo := authorizerToken["authorizer_info"].(map[string]interface{})
-	return authorizerInfo, nil
+	return toMap(&resp.AuthorizerInfo), nil
 }
 
 // ApiComponentToken 获取第三方平台component_access_token
 func (self *Client) ApiComponentToken() (string, error) {
-	exist := self.Cache.Exists(ComponentTokenCacheKeyPrefix + self.AppId)
-	if !exist {
-		componentToken, err := self.getRawApiComponentToken()
-		if err != nil {
-			log.Println(err)
-			return "", err
-		}
-		if _, ok := componentToken["component_access_token"]; !ok {
-			return "", errors.New("获取组件Token失败")
+	return self.ApiComponentTokenCtx(context.Background())
+}
+
+// ApiComponentTokenCtx 获取第三方平台component_access_token
+func (self *Client) ApiComponentTokenCtx(ctx context.Context) (string, error) {
+	token, ok := self.cachedComponentToken()
+	if ok {
+		return token, nil
+	}
+
+	// 同一进程内只有一个刷新, 其他请求等待其结果, ctx 取消时放弃等待
+	self.componentTokenMu.Lock()
+	call := self.componentTokenCall
+	if call == nil {
+		token, ok = self.cachedComponentToken()
+		if ok {
+			self.componentTokenMu.Unlock()
+			return token, nil
 		}
-		return componentToken["component_access_token"].(string), nil
+		call = &componentTokenCall{done: make(chan struct{})}
+		self.componentTokenCall = call
+		go self.refreshComponentTokenCall(call)
+	}
+	self.componentTokenMu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-call.done:
+		return call.token, call.err
+	}
+}
+
+// componentTokenCall 进行中的component_access_token刷新
+type componentTokenCall struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// refreshComponentTokenCall 刷新component_access_token并通知等待的请求,
+// 刷新不随某个请求的 ctx 取消, 请求超时由 Http 控制
+func (self *Client) refreshComponentTokenCall(call *componentTokenCall) {
+	defer func() {
+		self.componentTokenMu.Lock()
+		self.componentTokenCall = nil
+		self.componentTokenMu.Unlock()
+		close(call.done)
+	}()
+	ctx := context.Background()
+	if self.DistributedLock {
+		call.token, call.err = self.refreshComponentTokenWithLock(ctx, self.componentTokenLockTimeout())
+		return
+	}
+	call.token, call.err = self.refreshComponentToken(ctx)
+}
+
+// cachedComponentToken 读取缓存中未过期的component_access_token
+func (self *Client) cachedComponentToken() (string, bool) {
+	var cached componentTokenCache
+	err := self.getCache(ComponentTokenCacheKeyPrefix+self.AppId, &cached)
+	if err != nil || cached.ComponentAccessToken == "" || time.Now().Unix() > cached.ExpiresIn {
+		return "", false
+	}
+	return cached.ComponentAccessToken, true
+}
+
+// refreshComponentToken 请求新的component_access_token
+func (self *Client) refreshComponentToken(ctx context.Context) (string, error) {
+	componentToken, err := self.getRawApiComponentToken(ctx)
+	if err == nil && componentToken.ComponentAccessToken == "" {
+		err = errors.New("获取组件Token失败")
 	}
-	resp, err := self.Cache.Get(ComponentTokenCacheKeyPrefix + self.AppId)
+	self.recordRefreshFailure(ComponentTokenFailureCacheKeyPrefix+self.AppId, err)
 	if err != nil {
 		log.Println(err)
 		return "", err
 	}
-	componentToken := util.JsonUnmarshal(resp)
-	if componentToken == nil {
-		return "", err
-	}
-	if time.Now().Unix() > int64(componentToken["expires_in"].(float64)) {
-		componentToken, err := self.getRawApiComponentToken()
+	return componentToken.ComponentAccessToken, nil
+}
+
+// refreshComponentTokenWithLock 通过缓存锁保证多个实例中只有一个刷新component_access_token.
+// 持有锁的实例异常退出时锁在 lockTimeout 后过期, 等待时间长于锁的有效期, 以便锁过期后接手刷新
+func (self *Client) refreshComponentTokenWithLock(ctx context.Context, lockTimeout time.Duration) (string, error) {
+	lockKey := ComponentTokenLockCacheKeyPrefix + self.AppId
+	// 缓存的有效期以秒为单位向上取整
+	deadline := time.Now().Add(lockTimeout + time.Second)
+	for {
+		unlock, locked, err := self.tryLock(lockKey, lockTimeout)
 		if err != nil {
 			log.Println(err)
 			return "", err
 		}
-		return componentToken["component_access_token"].(string), nil
+		if locked {
+			defer unlock()
+			// 获取锁前可能已被其他实例刷新
+			token, ok := self.cachedComponentToken()
+			if ok {
+				return token, nil
+			}
+			return self.refreshComponentToken(ctx)
+		}
+		// 锁过期后仍被其他实例持有, 不在没有锁的情况下刷新
+		if time.Now().After(deadline) {
+			return "", ErrComponentTokenLockTimeout
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(componentTokenLockRetryInterval):
+		}
+		token, ok := self.cachedComponentToken()
+		if ok {
+			return token, nil
+		}
+	}
+}
+
+// componentTokenLockTimeout 锁的有效期, 刷新时可能使用上一个票据重试, 需覆盖两次请求的超时时间
+func (self *Client) componentTokenLockTimeout() time.Duration {
+	timeout := 2*self.Http.Timeout() + componentTokenLockRetryInterval
+	if timeout < ComponentTokenLockTimeout {
+		return ComponentTokenLockTimeout
 	}
-	return componentToken["component_access_token"].(string), nil
+	return timeout
 }
 
 // getRawApiComponentToken 获取第三方平台component_access_token
-func (self *Client) getRawApiComponentToken() (map[string]interface{}, error) {
-	dst, err := json.Marshal(map[string]interface{}{
-		"component_appid":         self.AppId,
-		"component_appsecret":     self.AppSecret,
-		"component_verify_ticket": self.getComponentTicket(),
-	})
-	status, body, err := self.Http.Post(self.Endpoint.ComponentAccessTokenUrl(), "application/json", dst)
+func (self *Client) getRawApiComponentToken(ctx context.Context) (*ComponentAccessToken, error) {
+	ticket, err := self.ComponentTicket()
 	if err != nil {
 		log.Println(err)
-		return nil, err
+		ticket = &core.ComponentTicket{}
 	}
-	if status != http.StatusOK {
-		log.Print(err)
+	componentToken, err := self.requestComponentToken(ctx, ticket.ComponentVerifyTicket)
+	if core.IsInvalidTicket(err) && ticket.PreviousTicket != "" && ticket.PreviousTicket != ticket.ComponentVerifyTicket {
+		// 新推送的票据尚未生效或已被轮换时使用上一个票据重试
+		log.Println(err)
+		componentToken, err = self.requestComponentToken(ctx, ticket.PreviousTicket)
+	}
+	if err != nil {
 		return nil, err
 	}
-	componentToken := util.JsonUnmarshalBytes(body)
-	componentToken["expires_in"] = time.Now().Unix() + 6600
-	_ = self.Cache.SetEx(ComponentTokenCacheKeyPrefix+self.AppId, componentToken, 6600)
+	_ = self.Cache.SetEx(ComponentTokenCacheKeyPrefix+self.AppId, &componentTokenCache{
+		ComponentAccessToken: componentToken.ComponentAccessToken,
+		ExpiresIn:            time.Now().Unix() + 6600,
+	}, 6600)
 	return componentToken, nil
 }
 
-// getComponentTicket 获取component_verify_ticket
-func (self *Client) getComponentTicket() (ticket string) {
-	exist := self.Cache.Exists(ComponentTicketCacheKeyPrefix + self.AppId)
-	if !exist {
-		return ""
+func (self *Client) requestComponentToken(ctx context.Context, componentVerifyTicket string) (*ComponentAccessToken, error) {
+	var componentToken ComponentAccessToken
+	err := self.postJSON(ctx, self.Endpoint.ComponentAccessTokenUrl(), &ComponentAccessTokenRequest{
+		ComponentAppid:        self.AppId,
+		ComponentAppsecret:    self.AppSecret,
+		ComponentVerifyTicket: componentVerifyTicket,
+	}, &componentToken)
+	if err != nil {
+		return nil, err
 	}
-	resp, _ := self.Cache.Get(ComponentTicketCacheKeyPrefix + self.AppId)
-	log.Println(ticket)
-	log.Println(resp)
-	componentVerifyTicket := util.JsonUnmarshal(resp)
-	if componentVerifyTicket == nil {
-		return ""
+	return &componentToken, nil
+}
+
+// ComponentTicket 读取缓存的 component_verify_ticket 及其接收时间
+func (self *Client) ComponentTicket() (*core.ComponentTicket, error) {
+	return core.LoadComponentTicket(self.Cache, self.AppId)
+}
+
+// CreateFastRegisterWeapp 快速注册小程序
+func (self *Client) CreateFastRegisterWeapp(req *FastRegisterWeappRequest) error {
+	return self.CreateFastRegisterWeappCtx(context.Background(), req)
+}
+
+// CreateFastRegisterWeappCtx 快速注册小程序
+func (self *Client) CreateFastRegisterWeappCtx(ctx context.Context, req *FastRegisterWeappRequest) error {
+	token, err := self.ApiComponentTokenCtx(ctx)
+	if err != nil {
+		return err
 	}
-	return string(componentVerifyTicket["component_verify_ticket"].(string))
+	return self.postJSON(ctx, self.Endpoint.FastRegisterWeapp(token), req, nil)
 }
 
 // FastRegisterWeapp 快速注册小程序
+//
+// Deprecated: 使用 CreateFastRegisterWeapp
 func (self *Client) FastRegisterWeapp(data map[string]interface{}) error {
-	dst, err := json.Marshal(data)
 	token, err := self.ApiComponentToken()
 	if err != nil {
 		return err
 	}
-	status, body, err := self.Http.Post(self.Endpoint.FastRegisterWeapp(token), "application/json", dst)
+	return self.postJSON(context.Background(), self.Endpoint.FastRegisterWeapp(token), data, nil)
+}
+
+// SearchFastRegisterWeapp 快速注册小程序结果查询
+func (self *Client) SearchFastRegisterWeapp(req *FastRegisterWeappSearchRequest) error {
+	return self.SearchFastRegisterWeappCtx(context.Background(), req)
+}
+
+// SearchFastRegisterWeappCtx 快速注册小程序结果查询
+func (self *Client) SearchFastRegisterWeappCtx(ctx context.Context, req *FastRegisterWeappSearchRequest) error {
+	token, err := self.ApiComponentTokenCtx(ctx)
 	if err != nil {
 		return err
 	}
-	if status != http.StatusOK {
-		return errors.New("网络错误")
-	}
-	resp := util.JsonUnmarshalBytes(body)
-	log.Println(resp)
-	if int(resp["errcode"].(float64)) != 0 {
-		return errors.New("注册失败:" + resp["errmsg"].(string))
-	}
-
-	return nil
+	return self.postJSON(ctx, self.Endpoint.FastRegisterWeappSearch(token), req, nil)
 }
 
 // FastRegisterWeappSearch 快速注册小程序结果查询
+//
+// Deprecated: 使用 SearchFastRegisterWeapp
 func (self *Client) FastRegisterWeappSearch(data map[string]interface{}) error {
-	dst, err := json.Marshal(data)
 	token, err := self.ApiComponentToken()
 	if err != nil {
 		return err
 	}
-	status, body, err := self.Http.Post(self.Endpoint.FastRegisterWeappSearch(token), "application/json", dst)
-	if err != nil {
-		return err
-	}
-	if status != http.StatusOK {
-		return errors.New("网络错误")
-	}
-	resp := util.JsonUnmarshalBytes(body)
-	log.Println(resp)
-	if int(resp["errcode"].(float64)) != 0 {
-		return errors.New("注册失败:" + resp["errmsg"].(string))
-	}
+	return self.postJSON(context.Background(), self.Endpoint.FastRegisterWeappSearch(token), data, nil)
+}
 
-	return nil
+// BindTester 绑定体验者账号, 返回的 UserStr 可用于 UnbindTesterByUserStr
+func (self *Client) BindTester(authorizerAccessToken, wechatId string) (*BindTesterResponse, error) {
+	return self.BindTesterCtx(context.Background(), authorizerAccessToken, wechatId)
 }
 
-// BindTester 绑定体验者账号
-func (self *Client) BindTester(authorizerAccessToken, wechatId string) error {
-	dst, err := json.Marshal(map[string]interface{}{
-		"wechatid": wechatId,
-	})
-	status, body, err := self.Http.Post(self.Endpoint.BindTester(authorizerAccessToken), "application/json", dst)
+// BindTesterCtx 绑定体验者账号
+func (self *Client) BindTesterCtx(ctx context.Context, authorizerAccessToken, wechatId string) (*BindTesterResponse, error) {
+	var resp BindTesterResponse
+	err := self.postJSON(ctx, self.Endpoint.BindTester(authorizerAccessToken), &BindTesterRequest{
+		WechatId: wechatId,
+	}, &resp)
 	if err != nil {
-		log.Println(err)
-		return err
-	}
-	if status != http.StatusOK {
-		return errors.New("网络错误")
-	}
-	resp := util.JsonUnmarshalBytes(body)
-	if int(resp["errcode"].(float64)) != 0 {
-		return errors.New("操作失败:" + resp["errmsg"].(string))
+		return nil, err
 	}
-	return nil
+	return &resp, nil
 }
 
 // UnbindTester 解除绑定体验者账号
 func (self *Client) UnbindTester(authorizerAccessToken, wechatId string) error {
-	dst, err := json.Marshal(map[string]interface{}{
-		"wechatid": wechatId,
-	})
-	status, body, err := self.Http.Post(self.Endpoint.UnbindTester(authorizerAccessToken), "application/json", dst)
+	return self.UnbindTesterCtx(context.Background(), authorizerAccessToken, wechatId)
+}
+
+// UnbindTesterCtx 解除绑定体验者账号
+func (self *Client) UnbindTesterCtx(ctx context.Context, authorizerAccessToken, wechatId string) error {
+	return self.postJSON(ctx, self.Endpoint.UnbindTester(authorizerAccessToken), &UnbindTesterRequest{
+		WechatId: wechatId,
+	}, nil)
+}
+
+// UnbindTesterByUserStr 使用绑定时返回的 userstr 解除绑定体验者账号
+func (self *Client) UnbindTesterByUserStr(authorizerAccessToken, userStr string) error {
+	return self.UnbindTesterByUserStrCtx(context.Background(), authorizerAccessToken, userStr)
+}
+
+// UnbindTesterByUserStrCtx 使用绑定时返回的 userstr 解除绑定体验者账号
+func (self *Client) UnbindTesterByUserStrCtx(ctx context.Context, authorizerAccessToken, userStr string) error {
+	return self.postJSON(ctx, self.Endpoint.UnbindTester(authorizerAccessToken), &UnbindTesterRequest{
+		UserStr: userStr,
+	}, nil)
+}
+
+// ModifyServerDomain 修改小程序服务器域名
+func (self *Client) ModifyServerDomain(authorizerAccessToken string, req *ModifyDomainRequest) (*ModifyDomainResponse, error) {
+	return self.ModifyServerDomainCtx(context.Background(), authorizerAccessToken, req)
+}
+
+// ModifyServerDomainCtx 修改小程序服务器域名
+func (self *Client) ModifyServerDomainCtx(ctx context.Context, authorizerAccessToken string, req *ModifyDomainRequest) (*ModifyDomainResponse, error) {
+	var resp ModifyDomainResponse
+	err := self.postJSON(ctx, self.Endpoint.ModifyDomain(authorizerAccessToken), req, &resp)
 	if err != nil {
-		return err
-	}
-	if status != http.StatusOK {
-		return errors.New("网络错误")
-	}
-	resp := util.JsonUnmarshalBytes(body)
-	log.Println(resp)
-	if int(resp["errcode"].(float64)) != 0 {
-		return errors.New("操作失败:" + resp["errmsg"].(string))
+		return nil, err
 	}
-	return nil
+	return &resp, nil
 }
 
 // ModifyDomain 修改小程序服务器域名
+//
+// Deprecated: 使用 ModifyServerDomain
 func (self *Client) ModifyDomain(authorizerAccessToken string, data map[string]interface{}) error {
-	dst, err := json.Marshal(data)
-	status, body, err := self.Http.Post(self.Endpoint.ModifyDomain(authorizerAccessToken), "application/json", dst)
-	if err != nil {
-		log.Println(err)
-		return err
-	}
-	if status != http.StatusOK {
-		return errors.New("网络错误")
-	}
-	resp := util.JsonUnmarshalBytes(body)
-	log.Println(resp)
-	if int(resp["errcode"].(float64)) != 0 {
-		return errors.New("操作失败:" + resp["errmsg"].(string))
-	}
-	return nil
+	return self.postJSON(context.Background(), self.Endpoint.ModifyDomain(authorizerAccessToken), data, nil)
+}
+
+// Commit 上传小程序代码
+func (self *Client) Commit(authorizerAccessToken string, req *CommitCodeRequest) error {
+	return self.CommitCtx(context.Background(), authorizerAccessToken, req)
+}
+
+// CommitCtx 上传小程序代码
+func (self *Client) CommitCtx(ctx context.Context, authorizerAccessToken string, req *CommitCodeRequest) error {
+	return self.postJSON(ctx, self.Endpoint.CommitCode(authorizerAccessToken), req, nil)
 }
 
 // CommitCode 上传小程序代码
+//
+// Deprecated: 使用 Commit
 func (self *Client) CommitCode(authorizerAccessToken string, data map[string]interface{}) error {
-	dst, err := json.Marshal(data)
-	status, body, err := self.Http.Post(self.Endpoint.CommitCode(authorizerAccessToken), "application/json", dst)
+	return self.postJSON(context.Background(), self.Endpoint.CommitCode(authorizerAccessToken), data, nil)
+}
+
+// SubmitCodeAudit 提交审核
+func (self *Client) SubmitCodeAudit(authorizerAccessToken string, req *SubmitAuditRequest) (*SubmitAuditResponse, error) {
+	return self.SubmitCodeAuditCtx(context.Background(), authorizerAccessToken, req)
+}
+
+// SubmitCodeAuditCtx 提交审核
+func (self *Client) SubmitCodeAuditCtx(ctx context.Context, authorizerAccessToken string, req *SubmitAuditRequest) (*SubmitAuditResponse, error) {
+	var resp SubmitAuditResponse
+	err := self.postJSON(ctx, self.Endpoint.SubmitAudit(authorizerAccessToken), req, &resp)
 	if err != nil {
-		log.Println(err)
-		return err
-	}
-	if status != http.StatusOK {
-		return errors.New("网络错误")
-	}
-	resp := util.JsonUnmarshalBytes(body)
-	log.Println(resp)
-	if int(resp["errcode"].(float64)) != 0 {
-		return errors.New("操作失败:" + resp["errmsg"].(string))
+		return nil, err
 	}
-	return nil
+	return &resp, nil
 }
 
 // SubmitAudit 提交审核
+//
+// Deprecated: 使用 SubmitCodeAudit
 func (self *Client) SubmitAudit(authorizerAccessToken string, data map[string]interface{}) error {
-	dst, err := json.Marshal(data)
-	status, body, err := self.Http.Post(self.Endpoint.SubmitAudit(authorizerAccessToken), "application/json", dst)
-	if err != nil {
-		log.Println(err)
-		return err
-	}
-	if status != http.StatusOK {
-		return errors.New("网络错误")
-	}
-	resp := util.JsonUnmarshalBytes(body)
-	log.Println(resp)
-	if int(resp["errcode"].(float64)) != 0 {
-		return errors.New("操作失败:" + resp["errmsg"].(string))
-	}
-	return nil
+	return self.postJSON(context.Background(), self.Endpoint.SubmitAudit(authorizerAccessToken), data, nil)
+}
+
+// UndoAudit 审核撤回
+func (self *Client) UndoAudit(authorizerAccessToken string) error {
+	return self.UndoAuditCtx(context.Background(), authorizerAccessToken)
+}
+
+// UndoAuditCtx 审核撤回
+func (self *Client) UndoAuditCtx(ctx context.Context, authorizerAccessToken string) error {
+	return self.getJSON(ctx, self.Endpoint.UndoCodeAudit(authorizerAccessToken), nil)
 }
 
 // UndoCodeAudit 审核撤回
+//
+// Deprecated: 使用 UndoAudit
 func (self *Client) UndoCodeAudit(authorizerAccessToken string, data map[string]interface{}) error {
-	dst, err := json.Marshal(data)
-	status, body, err := self.Http.Post(self.Endpoint.SubmitAudit(authorizerAccessToken), "application/json", dst)
-	if err != nil {
-		log.Println(err)
-		return err
-	}
-	if status != http.StatusOK {
-		return errors.New("网络错误")
-	}
-	resp := util.JsonUnmarshalBytes(body)
-	log.Println(resp)
-	if int(resp["errcode"].(float64)) != 0 {
-		return errors.New("操作失败:" + resp["errmsg"].(string))
-	}
-	return nil
+	return self.UndoAudit(authorizerAccessToken)
+}
+
+// ReleaseCode 小程序发布
+func (self *Client) ReleaseCode(authorizerAccessToken string) error {
+	return self.ReleaseCodeCtx(context.Background(), authorizerAccessToken)
+}
+
+// ReleaseCodeCtx 小程序发布
+func (self *Client) ReleaseCodeCtx(ctx context.Context, authorizerAccessToken string) error {
+	return self.postJSON(ctx, self.Endpoint.Release(authorizerAccessToken), struct{}{}, nil)
 }
 
 // Release 小程序发布
+//
+// Deprecated: 使用 ReleaseCode
 func (self *Client) Release(authorizerAccessToken string, data map[string]interface{}) error {
-	dst, err := json.Marshal(data)
-	status, body, err := self.Http.Post(self.Endpoint.Release(authorizerAccessToken), "application/json", dst)
-	if err != nil {
-		log.Println(err)
-		return err
-	}
-	if status != http.StatusOK {
-		return errors.New("网络错误")
-	}
-	resp := util.JsonUnmarshalBytes(body)
-	log.Println(resp)
-	if int(resp["errcode"].(float64)) != 0 {
-		return errors.New("操作失败:" + resp["errmsg"].(string))
-	}
-	return nil
+	return self.postJSON(context.Background(), self.Endpoint.Release(authorizerAccessToken), data, nil)
+}
+
+// CreateWxaCode 小程序码
+func (self *Client) CreateWxaCode(authorizerAccessToken string, req *GetWxaCodeRequest) ([]byte, error) {
+	return self.CreateWxaCodeCtx(context.Background(), authorizerAccessToken, req)
+}
+
+// CreateWxaCodeCtx 小程序码
+func (self *Client) CreateWxaCodeCtx(ctx context.Context, authorizerAccessToken string, req *GetWxaCodeRequest) ([]byte, error) {
+	return self.postBinary(ctx, self.Endpoint.GetWxaCode(authorizerAccessToken), req)
 }
 
 // GetWxaCode 小程序码
+//
+// Deprecated: 使用 CreateWxaCode
 func (self *Client) GetWxaCode(authorizerAccessToken string, data map[string]interface{}) ([]byte, error) {
-	dst, err := json.Marshal(data)
-	status, body, err := self.Http.Post(self.Endpoint.GetWxaCode(authorizerAccessToken), "application/json", dst)
+	return self.postBinary(context.Background(), self.Endpoint.GetWxaCode(authorizerAccessToken), data)
+}
+
+// LatestAuditStatus 获取小程序最后一次审核状态
+func (self *Client) LatestAuditStatus(authorizerAccessToken string) (*AuditStatus, error) {
+	return self.LatestAuditStatusCtx(context.Background(), authorizerAccessToken)
+}
+
+// LatestAuditStatusCtx 获取小程序最后一次审核状态
+func (self *Client) LatestAuditStatusCtx(ctx context.Context, authorizerAccessToken string) (*AuditStatus, error) {
+	var resp AuditStatus
+	err := self.getJSON(ctx, self.Endpoint.GetLastAuditStatus(authorizerAccessToken), &resp)
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
-	if status != http.StatusOK {
-		return nil, errors.New("网络错误")
-	}
-	resp := util.JsonUnmarshalBytes(body)
-	log.Println(resp)
-	if _, ok := resp["errcode"]; ok {
-		return nil, errors.New("操作失败:" + resp["errmsg"].(string))
-	}
-	return body, nil
+	return &resp, nil
 }
 
 // GetLastAuditStatus 获取小程序最后一次审核状态
+//
+// Deprecated: 使用 LatestAuditStatus
 func (self *Client) GetLastAuditStatus(authorizerAccessToken string) (map[string]interface{}, error) {
-	status, body, err := self.Http.Get(self.Endpoint.GetLastAuditStatus(authorizerAccessToken))
+	var resp map[string]interface{}
+	err := self.getJSON(context.Background(), self.Endpoint.GetLastAuditStatus(authorizerAccessToken), &resp)
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
-	if status != http.StatusOK {
-		return nil, errors.New("网络错误")
-	}
-	resp := util.JsonUnmarshalBytes(body)
-	log.Println(resp)
-	if int(resp["errcode"].(float64)) != 0 {
-		return nil, errors.New("操作失败:" + resp["errmsg"].(string))
-	}
 	return resp, nil
 }
 
-// GetTemplateList 获取小程序代码模板
-func (self *Client) GetTemplateList() (map[string]interface{}, error) {
-	token, err := self.ApiComponentToken()
+// ListTemplates 获取小程序代码模板
+func (self *Client) ListTemplates() (*TemplateList, error) {
+	return self.ListTemplatesCtx(context.Background())
+}
+
+// ListTemplatesCtx 获取小程序代码模板
+func (self *Client) ListTemplatesCtx(ctx context.Context) (*TemplateList, error) {
+	token, err := self.ApiComponentTokenCtx(ctx)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
-	status, body, err := self.Http.Get(self.Endpoint.GetTemplateList(token))
+	var resp TemplateList
+	err = self.getJSON(ctx, self.Endpoint.GetTemplateList(token), &resp)
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
-	if status != http.StatusOK {
-		return nil, errors.New("网络错误")
+	return &resp, nil
+}
+
+// GetTemplateList 获取小程序代码模板
+//
+// Deprecated: 使用 ListTemplates
+func (self *Client) GetTemplateList() (map[string]interface{}, error) {
+	resp, err := self.ListTemplates()
+	if err != nil {
+		return nil, err
 	}
-	resp := util.JsonUnmarshalBytes(body)
-	log.Println(resp)
-	if int(resp["errcode"].(float64)) != 0 {
-		return nil, errors.New("操作失败:" + resp["errmsg"].(string))
+	return toMap(resp), nil
+}
+
+// ListPages 获取已上传的代码的页面列表
+func (self *Client) ListPages(authorizerAccessToken string) (*PageList, error) {
+	return self.ListPagesCtx(context.Background(), authorizerAccessToken)
+}
+
+// ListPagesCtx 获取已上传的代码的页面列表
+func (self *Client) ListPagesCtx(ctx context.Context, authorizerAccessToken string) (*PageList, error) {
+	var resp PageList
+	err := self.getJSON(ctx, self.Endpoint.GetPage(authorizerAccessToken), &resp)
+	if err != nil {
+		return nil, err
 	}
-	return resp, nil
+	return &resp, nil
 }
 
 // GetPage 获取已上传的代码的页面列表
+//
+// Deprecated: 使用 ListPages
 func (self *Client) GetPage(authorizerAccessToken string) (map[string]interface{}, error) {
-	status, body, err := self.Http.Get(self.Endpoint.GetPage(authorizerAccessToken))
+	resp, err := self.ListPages(authorizerAccessToken)
 	if err != nil {
 		return nil, err
 	}
-	if status != http.StatusOK {
-		return nil, errors.New("网络错误")
-	}
-	resp := util.JsonUnmarshalBytes(body)
-	log.Println(resp)
-	if int(resp["errcode"].(float64)) != 0 {
-		return nil, errors.New("操作失败:" + resp["errmsg"].(string))
-	}
-	return resp, nil
+	return toMap(resp), nil
 }
 
-// MpLogin 第三方授权小程序登录
-func (self *Client) MpLogin(authorizerAppId, code string) (map[string]interface{}, error) {
-	token, err := self.ApiComponentToken()
+// JsCode2Session 第三方授权小程序登录
+func (self *Client) JsCode2Session(authorizerAppId, code string) (*Session, error) {
+	return self.JsCode2SessionCtx(context.Background(), authorizerAppId, code)
+}
+
+// JsCode2SessionCtx 第三方授权小程序登录
+func (self *Client) JsCode2SessionCtx(ctx context.Context, authorizerAppId, code string) (*Session, error) {
+	token, err := self.ApiComponentTokenCtx(ctx)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
-	status, body, err := self.Http.Get(self.Endpoint.JsCode2Session(authorizerAppId, code, self.AppId, token))
+	var resp Session
+	err = self.getJSON(ctx, self.Endpoint.JsCode2Session(authorizerAppId, code, self.AppId, token), &resp)
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
-	if status != http.StatusOK {
-		return nil, errors.New("网络错误")
-	}
-	resp := util.JsonUnmarshalBytes(body)
-	log.Println(resp)
-	if _, ok := resp["errcode"]; ok {
-		return nil, errors.New("操作失败:" + resp["errmsg"].(string))
-	}
-	return resp, nil
+	return &resp, nil
 }
 
-// GetQrCode 小程序体验码
-func (self *Client) GetQrCode(authorizerAccessToken, path string) ([]byte, error) {
-	status, body, err := self.Http.Get(self.Endpoint.GetQrCode(authorizerAccessToken, path))
+// MpLogin 第三方授权小程序登录
+//
+// Deprecated: 使用 JsCode2Session
+func (self *Client) MpLogin(authorizerAppId, code string) (map[string]interface{}, error) {
+	resp, err := self.JsCode2Session(authorizerAppId, code)
 	if err != nil {
 		return nil, err
 	}
-	if status != http.StatusOK {
-		return nil, errors.New("网络错误")
-	}
-	resp := util.JsonUnmarshalBytes(body)
-	log.Println(resp)
-	if _, ok := resp["errcode"]; ok {
-		return nil, errors.New("操作失败:" + resp["errmsg"].(string))
-	}
-	return body, nil
+	return toMap(resp), nil
 }
 
 // GetQrCode 小程序体验码
+func (self *Client) GetQrCode(authorizerAccessToken, path string) ([]byte, error) {
+	return self.GetQrCodeCtx(context.Background(), authorizerAccessToken, path)
+}
+
+// GetQrCodeCtx 小程序体验码
+func (self *Client) GetQrCodeCtx(ctx context.Context, authorizerAccessToken, path string) ([]byte, error) {
+	return self.getBinary(ctx, self.Endpoint.GetQrCode(authorizerAccessToken, url.QueryEscape(path)))
+}
+
+// GetQrCodeWithoutPath 小程序体验码
 func (self *Client) GetQrCodeWithoutPath(authorizerAccessToken string) ([]byte, error) {
-	status, body, err := self.Http.Get(self.Endpoint.GetQrCodeWithoutPath(authorizerAccessToken))
-	if err != nil {
-		return nil, err
-	}
-	if status != http.StatusOK {
-		return nil, errors.New("网络错误")
-	}
-	resp := util.JsonUnmarshalBytes(body)
-	log.Println(resp)
-	if _, ok := resp["errcode"]; ok {
-		return nil, errors.New("操作失败:" + resp["errmsg"].(string))
-	}
-	return body, nil
+	return self.GetQrCodeWithoutPathCtx(context.Background(), authorizerAccessToken)
+}
+
+// GetQrCodeWithoutPathCtx 小程序体验码
+func (self *Client) GetQrCodeWithoutPathCtx(ctx context.Context, authorizerAccessToken string) ([]byte, error) {
+	return self.getBinary(ctx, self.Endpoint.GetQrCodeWithoutPath(authorizerAccessToken))
 }
 
 // GetWxaQrCode 生成带参数小程序码
 func (self *Client) GetWxaQrCode(authorizerAccessToken, path string, width int) ([]byte, error) {
-	dst, err := json.Marshal(map[string]interface{}{
-		"path":  path,
-		"width": width,
+	return self.GetWxaQrCodeCtx(context.Background(), authorizerAccessToken, path, width)
+}
+
+// GetWxaQrCodeCtx 生成带参数小程序码
+func (self *Client) GetWxaQrCodeCtx(ctx context.Context, authorizerAccessToken, path string, width int) ([]byte, error) {
+	return self.postBinary(ctx, self.Endpoint.CreateWxaQrCode(authorizerAccessToken), &WxaQrCodeRequest{
+		Path:  path,
+		Width: width,
 	})
-	status, body, err := self.Http.Post(self.Endpoint.CreateWxaQrCode(authorizerAccessToken), "application/json", dst)
+}
+
+// ListTesters 获取小程序所有已绑定的体验者列表
+func (self *Client) ListTesters(authorizerAccessToken string) (*TesterList, error) {
+	return self.ListTestersCtx(context.Background(), authorizerAccessToken)
+}
+
+// ListTestersCtx 获取小程序所有已绑定的体验者列表
+func (self *Client) ListTestersCtx(ctx context.Context, authorizerAccessToken string) (*TesterList, error) {
+	var resp TesterList
+	err := self.postJSON(ctx, self.Endpoint.MemberAuth(authorizerAccessToken), &MemberAuthRequest{
+		Action: "get_experiencer",
+	}, &resp)
 	if err != nil {
 		return nil, err
 	}
-	if status != http.StatusOK {
-		return nil, errors.New("网络错误")
-	}
-	resp := util.JsonUnmarshalBytes(body)
-	log.Println(resp)
-	if _, ok := resp["errcode"]; ok {
-		return nil, errors.New("操作失败:" + resp["errmsg"].(string))
-	}
-	return body, nil
+	return &resp, nil
 }
 
 // MemberAuth 获取小程序所有已绑定的体验者列表
+//
+// Deprecated: 使用 ListTesters
 func (self *Client) MemberAuth(authorizerAccessToken string) (map[string]interface{}, error) {
-	status, body, err := self.Http.Get(self.Endpoint.MemberAuth(authorizerAccessToken))
+	resp, err := self.ListTesters(authorizerAccessToken)
 	if err != nil {
 		return nil, err
 	}
-	if status != http.StatusOK {
-		return nil, errors.New("网络错误")
-	}
-	resp := util.JsonUnmarshalBytes(body)
-	log.Println(resp)
-	if _, ok := resp["errcode"]; ok {
-		return nil, errors.New("操作失败:" + resp["errmsg"].(string))
-	}
-	return resp, nil
+	return toMap(resp), nil
 }
 
 // OAuth2Authorize 获取服务号授权网址
@@ -609,67 +839,252 @@ func (self *Client) OAuth2Authorize(authorizerApppId, redirectUrl string) string
 	return self.Endpoint.OAuth2Authorize(authorizerApppId, url.QueryEscape(redirectUrl), self.AppId)
 }
 
-// OAuth2AccessToken 获取服务号授权信息
-func (self *Client) OAuth2AccessToken(authorizerApppId, code string) (map[string]interface{}, error) {
-	token, err := self.ApiComponentToken()
+// ExchangeOAuth2Code 获取服务号授权信息
+func (self *Client) ExchangeOAuth2Code(authorizerApppId, code string) (*OAuth2AccessToken, error) {
+	return self.ExchangeOAuth2CodeCtx(context.Background(), authorizerApppId, code)
+}
+
+// ExchangeOAuth2CodeCtx 获取服务号授权信息
+func (self *Client) ExchangeOAuth2CodeCtx(ctx context.Context, authorizerApppId, code string) (*OAuth2AccessToken, error) {
+	token, err := self.ApiComponentTokenCtx(ctx)
 	if err != nil {
 		return nil, err
 	}
-
-	status, body, err := self.Http.Get(self.Endpoint.OAuth2AccessToken(authorizerApppId, code, self.AppId, token))
+	var resp OAuth2AccessToken
+	err = self.getJSON(ctx, self.Endpoint.OAuth2AccessToken(authorizerApppId, code, self.AppId, token), &resp)
 	if err != nil {
 		return nil, err
 	}
-	if status != http.StatusOK {
-		return nil, errors.New("网络错误")
-	}
-	resp := util.JsonUnmarshalBytes(body)
-	log.Println(resp)
-	if _, ok := resp["errcode"]; ok {
-		return nil, errors.New("操作失败:" + resp["errmsg"].(string))
-	}
-	return resp, nil
+	return &resp, nil
 }
 
-// OAuth2RefreshToken
-func (self *Client) OAuth2RefreshToken(authorizerAppId, refreshToken string) (map[string]interface{}, error) {
-	token, err := self.ApiComponentToken()
+// OAuth2AccessToken 获取服务号授权信息
+//
+// Deprecated: 使用 ExchangeOAuth2Code
+func (self *Client) OAuth2AccessToken(authorizerApppId, code string) (map[string]interface{}, error) {
+	resp, err := self.ExchangeOAuth2Code(authorizerApppId, code)
 	if err != nil {
 		return nil, err
 	}
-	status, body, err := self.Http.Get(self.Endpoint.OAuth2RefreshToken(authorizerAppId, self.AppId, token, refreshToken))
+	return toMap(resp), nil
+}
+
+// RefreshOAuth2Token 刷新服务号网页授权access_token
+func (self *Client) RefreshOAuth2Token(authorizerAppId, refreshToken string) (*OAuth2AccessToken, error) {
+	return self.RefreshOAuth2TokenCtx(context.Background(), authorizerAppId, refreshToken)
+}
+
+// RefreshOAuth2TokenCtx 刷新服务号网页授权access_token
+func (self *Client) RefreshOAuth2TokenCtx(ctx context.Context, authorizerAppId, refreshToken string) (*OAuth2AccessToken, error) {
+	token, err := self.ApiComponentTokenCtx(ctx)
 	if err != nil {
 		return nil, err
 	}
-	if status != http.StatusOK {
-		return nil, errors.New("网络错误")
+	var resp OAuth2AccessToken
+	err = self.getJSON(ctx, self.Endpoint.OAuth2RefreshToken(authorizerAppId, self.AppId, token, refreshToken), &resp)
+	if err != nil {
+		return nil, err
 	}
-	authorizerRefreshToken := util.JsonUnmarshalBytes(body)
 	_ = self.Cache.SetEx(MpAuthorizerTokenCacheKeyPrefix+authorizerAppId, map[string]interface{}{
-		"authorizer_mp_access_token":  authorizerRefreshToken["authorizer_access_token"],
-		"authorizer_mp_refresh_token": authorizerRefreshToken["authorizer_refresh_token"],
+		"authorizer_mp_access_token":  resp.AccessToken,
+		"authorizer_mp_refresh_token": resp.RefreshToken,
 		"expires_in":                  time.Now().Unix() + 6600,
 	}, 6600)
-	return authorizerRefreshToken, nil
+	return &resp, nil
+}
+
+// OAuth2RefreshToken
+//
+// Deprecated: 使用 RefreshOAuth2Token
+func (self *Client) OAuth2RefreshToken(authorizerAppId, refreshToken string) (map[string]interface{}, error) {
+	resp, err := self.RefreshOAuth2Token(authorizerAppId, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	return toMap(resp), nil
+}
+
+// SendCustomMessage 发送客服消息
+func (self *Client) SendCustomMessage(authorizerAccessToken string, msg *CustomMessage) error {
+	return self.SendCustomMessageCtx(context.Background(), authorizerAccessToken, msg)
+}
+
+// SendCustomMessageCtx 发送客服消息
+func (self *Client) SendCustomMessageCtx(ctx context.Context, authorizerAccessToken string, msg *CustomMessage) error {
+	return self.postJSON(ctx, self.Endpoint.CustomService(authorizerAccessToken), msg, nil)
 }
 
 // CustomService
+//
+// Deprecated: 使用 SendCustomMessage
 func (self *Client) CustomService(authorizerAccessToken string, data map[string]interface{}) error {
+	return self.postJSON(context.Background(), self.Endpoint.CustomService(authorizerAccessToken), data, nil)
+}
+
+// AuthorizerTokenSource 获取授权方的令牌源, 同一授权方共享同一个令牌源
+func (self *Client) AuthorizerTokenSource(authorizerAppId string) *AuthorizerTokenSource {
+	self.tokenSourcesMu.Lock()
+	defer self.tokenSourcesMu.Unlock()
+	if self.tokenSources == nil {
+		self.tokenSources = make(map[string]*AuthorizerTokenSource)
+	}
+	source, ok := self.tokenSources[authorizerAppId]
+	if !ok {
+		source = NewAuthorizerTokenSource(self, authorizerAppId)
+		self.tokenSources[authorizerAppId] = source
+	}
+	return source
+}
+
+// refreshToken 读取授权方的刷新令牌, 缓存中没有时从 Store 读取并写回缓存
+func (self *Client) refreshToken(authorizerAppId string) string {
+	var cached authorizerTokenCache
+	err := self.getCache(AuthorizerTokenCacheKeyPrefix+authorizerAppId, &cached)
+	if err == nil && cached.AuthorizerRefreshToken != "" {
+		return cached.AuthorizerRefreshToken
+	}
+	var refreshToken string
+	err = self.getCache(AuthorizerRefreshTokenCacheKeyPrefix+authorizerAppId, &refreshToken)
+	if err == nil && refreshToken != "" {
+		return refreshToken
+	}
+	if self.Store == nil {
+		return ""
+	}
+	record, err := self.Store.Get(context.Background(), authorizerAppId)
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+	if record.RefreshToken != "" {
+		err = self.Cache.Set(AuthorizerRefreshTokenCacheKeyPrefix+authorizerAppId, record.RefreshToken)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	return record.RefreshToken
+}
+
+// saveRefreshToken 保存授权方的刷新令牌, 并登记到授权方列表
+func (self *Client) saveRefreshToken(authorizerAppId, refreshToken string) {
+	if refreshToken == "" {
+		return
+	}
+	err := self.Cache.Set(AuthorizerRefreshTokenCacheKeyPrefix+authorizerAppId, refreshToken)
+	if err != nil {
+		log.Println(err)
+	}
+	if self.Store != nil {
+		err = self.saveStoredRefreshToken(context.Background(), authorizerAppId, refreshToken)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	err = self.addAuthorizer(authorizerAppId)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// authorizerTokenExpires 令牌的缓存时间, 比微信返回的有效期提前10分钟过期
+func authorizerTokenExpires(expiresIn int64) int64 {
+	if expiresIn > 600 {
+		return expiresIn - 600
+	}
+	if expiresIn > 0 {
+		return expiresIn
+	}
+	return 6600
+}
+
+// getCache 读取缓存并解析JSON
+func (self *Client) getCache(key string, result interface{}) error {
+	resp, err := self.Cache.Get(key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(resp), result)
+}
+
+// postJSON 发送JSON请求并解析返回
+func (self *Client) postJSON(ctx context.Context, url string, data interface{}, result interface{}) error {
 	dst, err := json.Marshal(data)
 	if err != nil {
 		return err
 	}
-	status, body, err := self.Http.Post(self.Endpoint.CustomService(authorizerAccessToken), "", dst)
+	status, body, err := self.Http.PostCtx(ctx, url, "application/json", dst)
+	if err != nil {
+		return core.NewAPIError(url, err)
+	}
+	return decodeJSON(url, status, body, result)
+}
+
+// getJSON 发送GET请求并解析返回
+func (self *Client) getJSON(ctx context.Context, url string, result interface{}) error {
+	status, body, err := self.Http.GetCtx(ctx, url)
 	if err != nil {
+		return core.NewAPIError(url, err)
+	}
+	return decodeJSON(url, status, body, result)
+}
+
+// postBinary 发送JSON请求, 返回图片等二进制内容
+func (self *Client) postBinary(ctx context.Context, url string, data interface{}) ([]byte, error) {
+	dst, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	status, body, err := self.Http.PostCtx(ctx, url, "application/json", dst)
+	if err != nil {
+		return nil, core.NewAPIError(url, err)
+	}
+	return decodeBinary(url, status, body)
+}
+
+// getBinary 发送GET请求, 返回图片等二进制内容
+func (self *Client) getBinary(ctx context.Context, url string) ([]byte, error) {
+	status, body, err := self.Http.GetCtx(ctx, url)
+	if err != nil {
+		return nil, core.NewAPIError(url, err)
+	}
+	return decodeBinary(url, status, body)
+}
+
+// decodeJSON 检查errcode并解析返回
+func decodeJSON(url string, status int, body []byte, result interface{}) error {
+	err := core.CheckResponse(url, status, body)
+	if err != nil {
+		log.Println(err)
 		return err
 	}
-	if status != http.StatusOK {
-		return errors.New("网络错误")
+	if result == nil {
+		return nil
 	}
-	resp := util.JsonUnmarshalBytes(body)
-	log.Println(resp)
-	if _, ok := resp["errcode"]; ok {
-		return errors.New("操作失败:" + resp["errmsg"].(string))
+	err = json.Unmarshal(body, result)
+	if err != nil {
+		apiErr := core.NewAPIError(url, err)
+		apiErr.HTTPStatus = status
+		return apiErr
 	}
 	return nil
 }
+
+// decodeBinary 二进制接口出错时返回JSON
+func decodeBinary(url string, status int, body []byte) ([]byte, error) {
+	if status != http.StatusOK || (len(body) > 0 && body[0] == '{') {
+		err := decodeJSON(url, status, body, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+// toMap 将返回结构转换为map, 用于兼容旧接口
+func toMap(v interface{}) map[string]interface{} {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return util.JsonUnmarshalBytes(buf)
+}
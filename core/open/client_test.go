@@ -0,0 +1,163 @@
+package open
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mrwangjinjin/go-wechat/core"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeprecatedQueryAuthKeepsMapShape(t *testing.T) {
+	clients, cleanup := newTestClients(1, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"authorization_info":{"authorizer_appid":"wx_authorizer","authorizer_access_token":"access","expires_in":7200,` +
+			`"authorizer_refresh_token":"refresh","func_info":[{"funcscope_category":{"id":1}}]}}`))
+	})
+	defer cleanup()
+	client := clients[0]
+
+	now := time.Now().Unix()
+	resp, err := client.ApiQueryAuth("code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, err := client.GetToken("wx_authorizer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		resp map[string]interface{}
+	}{
+		{"ApiQueryAuth", resp},
+		{"GetToken", cached},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.resp["authorizer_appid"] != "wx_authorizer" {
+				t.Fatalf("authorizer_appid missing: %v", tt.resp)
+			}
+			if _, ok := tt.resp["func_info"]; !ok {
+				t.Fatalf("func_info missing: %v", tt.resp)
+			}
+			var expiresIn int64
+			switch v := tt.resp["expires_in"].(type) {
+			case int64:
+				expiresIn = v
+			case float64:
+				expiresIn = int64(v)
+			}
+			if expiresIn < now {
+				t.Fatalf("expires_in is not a timestamp: %v", tt.resp["expires_in"])
+			}
+		})
+	}
+}
+
+func TestBindTesterReturnsUserStr(t *testing.T) {
+	var unbindBody string
+	clients, cleanup := newTestClients(1, func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "unbind_tester") {
+			buf, _ := ioutil.ReadAll(r.Body)
+			unbindBody = string(buf)
+			_, _ = w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"errcode":0,"errmsg":"ok","userstr":"xxxxxxxxx"}`))
+	})
+	defer cleanup()
+	client := clients[0]
+
+	resp, err := client.BindTester("access", "wechat_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.UserStr != "xxxxxxxxx" {
+		t.Fatalf("expected userstr, got %q", resp.UserStr)
+	}
+	err = client.UnbindTesterByUserStr("access", resp.UserStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(unbindBody, `"userstr":"xxxxxxxxx"`) || strings.Contains(unbindBody, "wechatid") {
+		t.Fatalf("unexpected unbind request %s", unbindBody)
+	}
+}
+
+func TestComponentTokenRetriesPreviousTicket(t *testing.T) {
+	const (
+		invalidTicket = `{"errcode":61006,"errmsg":"component ticket is invalid"}`
+		otherError    = `{"errcode":40013,"errmsg":"invalid appid"}`
+		token         = `{"component_access_token":"token_%s","expires_in":7200}`
+	)
+	tests := []struct {
+		name      string
+		ticket    core.ComponentTicket
+		responses map[string]string // 按请求中的票据返回
+		tickets   []string          // 依次请求的票据
+		token     string
+	}{
+		{
+			name:      "current ticket accepted",
+			ticket:    core.ComponentTicket{ComponentVerifyTicket: "t2", PreviousTicket: "t1"},
+			responses: map[string]string{"t2": fmt.Sprintf(token, "t2")},
+			tickets:   []string{"t2"},
+			token:     "token_t2",
+		},
+		{
+			name:      "current ticket rejected",
+			ticket:    core.ComponentTicket{ComponentVerifyTicket: "t2", PreviousTicket: "t1"},
+			responses: map[string]string{"t2": invalidTicket, "t1": fmt.Sprintf(token, "t1")},
+			tickets:   []string{"t2", "t1"},
+			token:     "token_t1",
+		},
+		{
+			name:      "no previous ticket",
+			ticket:    core.ComponentTicket{ComponentVerifyTicket: "t2"},
+			responses: map[string]string{"t2": invalidTicket},
+			tickets:   []string{"t2"},
+		},
+		{
+			name:      "previous ticket is the same",
+			ticket:    core.ComponentTicket{ComponentVerifyTicket: "t2", PreviousTicket: "t2"},
+			responses: map[string]string{"t2": invalidTicket},
+			tickets:   []string{"t2"},
+		},
+		{
+			name:      "other errors are not retried",
+			ticket:    core.ComponentTicket{ComponentVerifyTicket: "t2", PreviousTicket: "t1"},
+			responses: map[string]string{"t2": otherError, "t1": fmt.Sprintf(token, "t1")},
+			tickets:   []string{"t2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested []string
+			clients, cleanup := newTestClients(1, func(w http.ResponseWriter, r *http.Request) {
+				var req ComponentAccessTokenRequest
+				_ = json.NewDecoder(r.Body).Decode(&req)
+				requested = append(requested, req.ComponentVerifyTicket)
+				_, _ = w.Write([]byte(tt.responses[req.ComponentVerifyTicket]))
+			})
+			defer cleanup()
+			client := clients[0]
+			_ = client.Cache.Delete(ComponentTokenCacheKeyPrefix + client.AppId)
+			_ = client.Cache.Set(core.ComponentTicketCacheKeyPrefix+client.AppId, &tt.ticket)
+
+			got, err := client.ApiComponentToken()
+			if tt.token == "" {
+				if err == nil {
+					t.Fatalf("expected error, got token %q", got)
+				}
+			} else if err != nil || got != tt.token {
+				t.Fatalf("expected %q, got %q err=%v", tt.token, got, err)
+			}
+			if strings.Join(requested, ",") != strings.Join(tt.tickets, ",") {
+				t.Fatalf("expected tickets %v, got %v", tt.tickets, requested)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package open
+
+import (
+	"context"
+	"fmt"
+	"github.com/mrwangjinjin/go-wechat/core"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	AuthorizerInfoCacheKeyPrefix    = "CACHE_AUTHORIZER_INFO@@"
+	AuthorizationCodeCacheKeyPrefix = "CACHE_AUTHORIZATION_CODE@@"
+)
+
+// AuthorizationCodeExpires 推送中没有授权码过期时间时, 授权码去重记录保留的秒数
+const AuthorizationCodeExpires = 3600
+
+// AuthorizationEvent 授权或授权更新后的授权方信息
+type AuthorizationEvent struct {
+	AuthorizerAppid   string
+	Updated           bool // 授权更新时为true
+	AuthorizationInfo *AuthorizationInfo
+	AuthorizerInfo    *AuthorizerInfoResponse
+}
+
+type AuthorizationHandler func(event *AuthorizationEvent)
+type UnauthorizationHandler func(authorizerAppId string)
+type LifecycleErrorHandler func(authorizerAppId string, err error)
+
+// AuthorizationLifecycle 收到授权推送时自动换取并缓存授权方令牌与信息, 取消授权时清除缓存.
+// 换取授权信息在推送的请求之外执行, 避免超过微信5秒的响应时间, 同一授权码重复推送时只换取一次
+type AuthorizationLifecycle struct {
+	client       *Client
+	authorized   []AuthorizationHandler
+	unauthorized []UnauthorizationHandler
+	errors       []LifecycleErrorHandler
+	wg           sync.WaitGroup
+}
+
+func NewAuthorizationLifecycle(client *Client) *AuthorizationLifecycle {
+	return &AuthorizationLifecycle{
+		client: client,
+	}
+}
+
+// Attach 注册到 core.Server 的授权推送处理函数
+func (self *AuthorizationLifecycle) Attach(server *core.Server) {
+	server.OnAuthorized(func(event *core.AuthorizedEvent) {
+		self.authorizeAsync(event.AuthorizerAppid, event.AuthorizationCode, event.AuthorizationCodeExpiredTime, false)
+	})
+	server.OnUpdateAuthorized(func(event *core.UpdateAuthorizedEvent) {
+		self.authorizeAsync(event.AuthorizerAppid, event.AuthorizationCode, event.AuthorizationCodeExpiredTime, true)
+	})
+	server.OnUnauthorized(func(event *core.UnauthorizedEvent) {
+		self.unauthorize(event.AuthorizerAppid)
+	})
+}
+
+// OnAuthorized 授权或授权更新, 令牌与授权方信息已缓存
+func (self *AuthorizationLifecycle) OnAuthorized(handler AuthorizationHandler) {
+	self.authorized = append(self.authorized, handler)
+}
+
+// OnUnauthorized 取消授权, 授权方缓存已清除
+func (self *AuthorizationLifecycle) OnUnauthorized(handler UnauthorizationHandler) {
+	self.unauthorized = append(self.unauthorized, handler)
+}
+
+// OnError 换取授权信息或清除缓存失败
+func (self *AuthorizationLifecycle) OnError(handler LifecycleErrorHandler) {
+	self.errors = append(self.errors, handler)
+}
+
+// Wait 等待进行中的授权处理完成, 用于退出前
+func (self *AuthorizationLifecycle) Wait() {
+	self.wg.Wait()
+}
+
+// authorizeAsync 授权码首次推送时在新的协程中换取授权信息, 授权码只能使用一次, 重复的推送直接忽略
+func (self *AuthorizationLifecycle) authorizeAsync(authorizerAppId, code string, expiredTime int64, updated bool) {
+	if !self.claimCode(code, expiredTime) {
+		return
+	}
+	self.wg.Add(1)
+	go func() {
+		defer self.wg.Done()
+		defer func() {
+			if e := recover(); e != nil {
+				self.fail(authorizerAppId, fmt.Errorf("授权处理异常: %v", e))
+			}
+		}()
+		self.authorize(authorizerAppId, code, updated)
+	}()
+}
+
+// claimCode 记录授权码, 已记录过时返回 false, 缓存不可用时不去重
+func (self *AuthorizationLifecycle) claimCode(code string, expiredTime int64) bool {
+	expires := expiredTime - time.Now().Unix()
+	if expires <= 0 {
+		expires = AuthorizationCodeExpires
+	}
+	ok, err := self.client.Cache.SetNX(AuthorizationCodeCacheKeyPrefix+code, 1, expires)
+	if err != nil {
+		log.Println(err)
+		return true
+	}
+	return ok
+}
+
+func (self *AuthorizationLifecycle) authorize(authorizerAppId, code string, updated bool) {
+	info, err := self.client.QueryAuth(code)
+	if err != nil {
+		self.fail(authorizerAppId, err)
+		return
+	}
+	authorizer, err := self.client.GetAuthorizerInfo(info.AuthorizerAppid)
+	if err != nil {
+		self.fail(authorizerAppId, err)
+		return
+	}
+	err = self.client.Cache.Set(AuthorizerInfoCacheKeyPrefix+info.AuthorizerAppid, authorizer)
+	if err != nil {
+		self.fail(authorizerAppId, err)
+		return
+	}
+	self.client.updateStoredAuthorizer(context.Background(), info.AuthorizerAppid, func(record *AuthorizerRecord) {
+		record.AuthorizerInfo = &authorizer.AuthorizerInfo
+	})
+	// core.Server 按消息的 ToUserName 查找授权方
+	err = self.client.Cache.Set(core.AuthorizerUserNameCacheKeyPrefix+authorizer.AuthorizerInfo.UserName, info.AuthorizerAppid)
+	if err != nil {
+		self.fail(authorizerAppId, err)
+		return
+	}
+	event := &AuthorizationEvent{
+		AuthorizerAppid:   info.AuthorizerAppid,
+		Updated:           updated,
+		AuthorizationInfo: info,
+		AuthorizerInfo:    authorizer,
+	}
+	for _, handler := range self.authorized {
+		handler(event)
+	}
+}
+
+func (self *AuthorizationLifecycle) unauthorize(authorizerAppId string) {
+	err := self.client.PurgeAuthorizer(authorizerAppId)
+	if err != nil {
+		self.fail(authorizerAppId, err)
+		return
+	}
+	for _, handler := range self.unauthorized {
+		handler(authorizerAppId)
+	}
+}
+
+func (self *AuthorizationLifecycle) fail(authorizerAppId string, err error) {
+	log.Println(err)
+	for _, handler := range self.errors {
+		handler(authorizerAppId, err)
+	}
+}
+
+// CachedAuthorizerInfo 读取授权时缓存的授权方信息
+func (self *Client) CachedAuthorizerInfo(authorizerAppId string) (*AuthorizerInfoResponse, error) {
+	var info AuthorizerInfoResponse
+	err := self.getCache(AuthorizerInfoCacheKeyPrefix+authorizerAppId, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// PurgeAuthorizer 清除授权方的令牌、刷新令牌、授权方信息与刷新失败记录缓存, 设置了 Store 时同时删除
+func (self *Client) PurgeAuthorizer(authorizerAppId string) error {
+	keys := []string{
+		AuthorizerTokenCacheKeyPrefix + authorizerAppId,
+		MpAuthorizerTokenCacheKeyPrefix + authorizerAppId,
+		AuthorizerRefreshTokenCacheKeyPrefix + authorizerAppId,
+		AuthorizerInfoCacheKeyPrefix + authorizerAppId,
+		AuthorizerRefreshFailureCacheKeyPrefix + authorizerAppId,
+	}
+	info, err := self.CachedAuthorizerInfo(authorizerAppId)
+	if err == nil && info.AuthorizerInfo.UserName != "" {
+		keys = append(keys, core.AuthorizerUserNameCacheKeyPrefix+info.AuthorizerInfo.UserName)
+	}
+	for _, key := range keys {
+		err := self.Cache.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	self.tokenSourcesMu.Lock()
+	delete(self.tokenSources, authorizerAppId)
+	self.tokenSourcesMu.Unlock()
+	return self.removeAuthorizer(authorizerAppId)
+}
@@ -0,0 +1,133 @@
+package open
+
+import (
+	"github.com/mrwangjinjin/go-wechat/core"
+	"github.com/mrwangjinjin/go-wechat/internal/util"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newNotifyPush 构造明文模式的授权推送
+func newNotifyPush(server *core.Server, nonce, infoType, code string) *http.Request {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	query := url.Values{
+		"timestamp": {timestamp},
+		"nonce":     {nonce},
+		"signature": {util.Sign(server.Token, timestamp, nonce)},
+	}
+	body := "<xml><AppId><![CDATA[wx_component]]></AppId><CreateTime>" + timestamp + "</CreateTime>" +
+		"<InfoType><![CDATA[" + infoType + "]]></InfoType><AuthorizerAppid><![CDATA[wx_authorizer]]></AuthorizerAppid>" +
+		"<AuthorizationCode><![CDATA[" + code + "]]></AuthorizationCode></xml>"
+	return httptest.NewRequest(http.MethodPost, "/notify?"+query.Encode(), strings.NewReader(body))
+}
+
+// newTestLifecycle 返回的函数关闭接口服务
+func newTestLifecycle(handler http.HandlerFunc) (*core.Server, *AuthorizationLifecycle, *Client, func()) {
+	clients, cleanup := newTestClients(1, handler)
+	client := clients[0]
+	server := core.NewServer(&core.ClientConfig{AppId: "wx_component", Token: "token"}, client.Cache)
+	lifecycle := NewAuthorizationLifecycle(client)
+	lifecycle.Attach(server)
+	return server, lifecycle, client, cleanup
+}
+
+func TestAuthorizationLifecycle(t *testing.T) {
+	var queryAuthCalls int32
+	tests := []struct {
+		name       string
+		infoType   string
+		queryAuth  string
+		authorized int
+		errors     int
+	}{
+		{"authorized", core.EventAuthorized, `{"authorization_info":{"authorizer_appid":"wx_authorizer","authorizer_access_token":"access","expires_in":7200,"authorizer_refresh_token":"refresh"}}`, 1, 0},
+		{"updateauthorized", core.EventUpdateAuthorized, `{"authorization_info":{"authorizer_appid":"wx_authorizer","authorizer_access_token":"access","expires_in":7200,"authorizer_refresh_token":"refresh"}}`, 1, 0},
+		{"invalid code", core.EventAuthorized, `{"errcode":61010,"errmsg":"code is expired"}`, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&queryAuthCalls, 0)
+			server, lifecycle, client, cleanup := newTestLifecycle(func(w http.ResponseWriter, r *http.Request) {
+				if strings.Contains(r.URL.Path, "api_query_auth") {
+					atomic.AddInt32(&queryAuthCalls, 1)
+					_, _ = w.Write([]byte(tt.queryAuth))
+					return
+				}
+				_, _ = w.Write([]byte(`{"authorizer_info":{"nick_name":"test","user_name":"gh_authorizer"}}`))
+			})
+			defer cleanup()
+			authorized, errors := 0, 0
+			lifecycle.OnAuthorized(func(event *AuthorizationEvent) {
+				authorized++
+				if event.Updated != (tt.infoType == core.EventUpdateAuthorized) {
+					t.Errorf("unexpected Updated %v", event.Updated)
+				}
+			})
+			lifecycle.OnError(func(authorizerAppId string, err error) {
+				errors++
+			})
+
+			// 微信未及时收到响应时以新的 nonce 重试同一授权码
+			for _, nonce := range []string{"1", "2"} {
+				w := httptest.NewRecorder()
+				server.Serve(w, newNotifyPush(server, nonce, tt.infoType, "code"), nil)
+				if w.Code != http.StatusOK {
+					t.Fatalf("expected 200, got %d", w.Code)
+				}
+				lifecycle.Wait()
+			}
+			if authorized != tt.authorized || errors != tt.errors {
+				t.Fatalf("expected authorized=%d errors=%d, got authorized=%d errors=%d", tt.authorized, tt.errors, authorized, errors)
+			}
+			if tt.errors == 0 && queryAuthCalls != 1 {
+				t.Fatalf("authorization code exchanged %d times", queryAuthCalls)
+			}
+			if tt.authorized > 0 {
+				if _, err := client.CachedAuthorizerInfo("wx_authorizer"); err != nil {
+					t.Fatalf("authorizer info not cached: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthorizationLifecycleUnauthorized(t *testing.T) {
+	server, lifecycle, client, cleanup := newTestLifecycle(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "api_query_auth") {
+			_, _ = w.Write([]byte(`{"authorization_info":{"authorizer_appid":"wx_authorizer","authorizer_access_token":"access","expires_in":7200,"authorizer_refresh_token":"refresh"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"authorizer_info":{"nick_name":"test","user_name":"gh_authorizer"}}`))
+	})
+	defer cleanup()
+	var unauthorized []string
+	lifecycle.OnUnauthorized(func(authorizerAppId string) {
+		unauthorized = append(unauthorized, authorizerAppId)
+	})
+
+	server.Serve(httptest.NewRecorder(), newNotifyPush(server, "1", core.EventAuthorized, "code"), nil)
+	lifecycle.Wait()
+	w := httptest.NewRecorder()
+	server.Serve(w, newNotifyPush(server, "2", core.EventUnauthorized, ""), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if len(unauthorized) != 1 || unauthorized[0] != "wx_authorizer" {
+		t.Fatalf("unexpected unauthorized calls %v", unauthorized)
+	}
+	if _, err := client.CachedAuthorizerInfo("wx_authorizer"); err == nil {
+		t.Fatal("authorizer info not purged")
+	}
+	if token := client.refreshToken("wx_authorizer"); token != "" {
+		t.Fatalf("refresh token not purged: %q", token)
+	}
+	if client.Cache.Exists(core.AuthorizerUserNameCacheKeyPrefix + "gh_authorizer") {
+		t.Fatal("user name mapping not purged")
+	}
+}
@@ -0,0 +1,29 @@
+package open
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"time"
+)
+
+// tryLock 以随机值写入缓存锁, 返回的 unlock 仅在锁仍由自己持有时删除
+func (self *Client) tryLock(key string, ttl time.Duration) (unlock func(), locked bool, err error) {
+	buf := make([]byte, 16)
+	_, err = rand.Read(buf)
+	if err != nil {
+		return nil, false, err
+	}
+	value := hex.EncodeToString(buf)
+	expires := int64((ttl + time.Second - 1) / time.Second)
+	locked, err = self.Cache.SetNX(key, value, expires)
+	if err != nil || !locked {
+		return nil, false, err
+	}
+	return func() {
+		_, err := self.Cache.DeleteIfEquals(key, value)
+		if err != nil {
+			log.Println(err)
+		}
+	}, true, nil
+}
@@ -0,0 +1,108 @@
+package open
+
+import (
+	"context"
+	"github.com/mrwangjinjin/go-wechat/core"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTryLockReleasesOnlyOwnLock(t *testing.T) {
+	client := NewClient(&core.ClientConfig{AppId: "wx_component"}, core.NewMemoryCache())
+	key := ComponentTokenLockCacheKeyPrefix + client.AppId
+
+	unlockA, locked, err := client.tryLock(key, time.Minute)
+	if err != nil || !locked {
+		t.Fatalf("first lock: locked=%v err=%v", locked, err)
+	}
+	_, locked, err = client.tryLock(key, time.Minute)
+	if err != nil || locked {
+		t.Fatalf("second lock while held: locked=%v err=%v", locked, err)
+	}
+
+	// 锁过期后被其他实例持有, 原持有者释放时不能删除
+	_ = client.Cache.Delete(key)
+	unlockB, locked, err := client.tryLock(key, time.Minute)
+	if err != nil || !locked {
+		t.Fatalf("lock after expiry: locked=%v err=%v", locked, err)
+	}
+	unlockA()
+	if !client.Cache.Exists(key) {
+		t.Fatal("stale holder released another instance's lock")
+	}
+	unlockB()
+	if client.Cache.Exists(key) {
+		t.Fatal("owner failed to release lock")
+	}
+}
+
+func TestComponentTokenLockOutlivesHttpTimeout(t *testing.T) {
+	client := NewClient(&core.ClientConfig{AppId: "wx_component"}, core.NewMemoryCache())
+	if client.componentTokenLockTimeout() <= client.Http.Timeout() {
+		t.Fatalf("lock timeout %v not longer than http timeout %v", client.componentTokenLockTimeout(), client.Http.Timeout())
+	}
+}
+
+func TestComponentTokenLockWaiterDoesNotRefreshWithoutLock(t *testing.T) {
+	client := NewClient(&core.ClientConfig{AppId: "wx_component", Timeout: time.Millisecond}, core.NewMemoryCache())
+	_, locked, _ := client.tryLock(ComponentTokenLockCacheKeyPrefix+client.AppId, time.Minute)
+	if !locked {
+		t.Fatal("failed to take lock")
+	}
+	start := time.Now()
+	_, err := client.refreshComponentTokenWithLock(context.Background(), time.Second)
+	if err != ErrComponentTokenLockTimeout {
+		t.Fatalf("expected ErrComponentTokenLockTimeout, got %v", err)
+	}
+	if time.Since(start) < time.Second {
+		t.Fatal("returned before the lock timeout")
+	}
+}
+
+func TestComponentTokenLockWaiterTakesOverExpiredLock(t *testing.T) {
+	clients, cleanup := newTestClients(1, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"component_access_token":"new_token","expires_in":7200}`))
+	})
+	defer cleanup()
+	client := clients[0]
+	_ = client.Cache.Delete(ComponentTokenCacheKeyPrefix + client.AppId)
+	// 持有锁的实例退出, 锁在有效期后过期
+	_, locked, _ := client.tryLock(ComponentTokenLockCacheKeyPrefix+client.AppId, time.Second)
+	if !locked {
+		t.Fatal("failed to take lock")
+	}
+	token, err := client.refreshComponentTokenWithLock(context.Background(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "new_token" {
+		t.Fatalf("expected new_token, got %q", token)
+	}
+}
+
+func TestComponentTokenWaiterHonorsContext(t *testing.T) {
+	release := make(chan struct{})
+	clients, cleanup := newTestClients(1, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		_, _ = w.Write([]byte(`{"component_access_token":"new_token","expires_in":7200}`))
+	})
+	defer cleanup()
+	client := clients[0]
+	defer close(release)
+	_ = client.Cache.Delete(ComponentTokenCacheKeyPrefix + client.AppId)
+
+	// 第一个请求发起刷新后放弃等待, 后续请求同样可以放弃等待
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		start := time.Now()
+		_, err := client.ApiComponentTokenCtx(ctx)
+		cancel()
+		if err != context.DeadlineExceeded {
+			t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+		}
+		if time.Since(start) > time.Second {
+			t.Fatal("waiter was not released on context cancellation")
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package open
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	AuthorizerListCacheKeyPrefix = "CACHE_AUTHORIZER_LIST@@"
+)
+
+const (
+	DefaultRefreshInterval    = time.Minute
+	DefaultRefreshLeeway      = 10 * time.Minute
+	DefaultRefreshJitter      = 2 * time.Minute
+	DefaultRefreshConcurrency = 8
+)
+
+// RefreshErrorHandler 授权方令牌刷新失败, 失败次数同时记录在 AuthorizerRefreshFailure
+type RefreshErrorHandler func(authorizerAppId string, err error)
+
+// Refresher 后台定时刷新所有授权方的令牌, 在令牌过期前 Leeway 加上随机 Jitter 时刷新,
+// 避免大量授权方在同一时刻刷新
+type Refresher struct {
+	client *Client
+	errors []RefreshErrorHandler
+
+	// Authorizers 需要刷新的授权方appid, 默认读取 Client.ListAuthorizers
+	Authorizers func(ctx context.Context) ([]string, error)
+	Interval    time.Duration
+	Leeway      time.Duration
+	Jitter      time.Duration
+	Concurrency int
+}
+
+func NewRefresher(client *Client) *Refresher {
+	return &Refresher{
+		client: client,
+		Authorizers: func(ctx context.Context) ([]string, error) {
+			return client.ListAuthorizersCtx(ctx)
+		},
+		Interval:    DefaultRefreshInterval,
+		Leeway:      DefaultRefreshLeeway,
+		Jitter:      DefaultRefreshJitter,
+		Concurrency: DefaultRefreshConcurrency,
+	}
+}
+
+// OnError 授权方令牌刷新失败
+func (self *Refresher) OnError(handler RefreshErrorHandler) {
+	self.errors = append(self.errors, handler)
+}
+
+// Run 每隔 Interval 检查一次所有授权方, Interval 未设置时使用 DefaultRefreshInterval,
+// ctx 取消后等待进行中的刷新完成再返回
+func (self *Refresher) Run(ctx context.Context) error {
+	interval := self.Interval
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := self.RefreshOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			self.fail("", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RefreshOnce 刷新即将过期的授权方令牌, 最多同时刷新 Concurrency 个
+func (self *Refresher) RefreshOnce(ctx context.Context) error {
+	authorizerAppIds, err := self.Authorizers(ctx)
+	if err != nil {
+		return err
+	}
+	concurrency := self.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultRefreshConcurrency
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, authorizerAppId := range authorizerAppIds {
+		leeway := self.leeway()
+		if !self.due(authorizerAppId, leeway) {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(authorizerAppId string, leeway time.Duration) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			_, err := self.client.AuthorizerTokenSource(authorizerAppId).refreshIfDue(ctx, leeway)
+			if err != nil && ctx.Err() == nil {
+				self.fail(authorizerAppId, err)
+			}
+		}(authorizerAppId, leeway)
+	}
+	wg.Wait()
+	return nil
+}
+
+// leeway Leeway 加上随机 Jitter
+func (self *Refresher) leeway() time.Duration {
+	leeway := self.Leeway
+	if self.Jitter > 0 {
+		leeway += time.Duration(rand.Int63n(int64(self.Jitter)))
+	}
+	return leeway
+}
+
+// due 令牌不存在或将在 leeway 内过期. 缺少刷新令牌的授权方只在第一次失败时通知,
+// 之后跳过直到重新授权, 失败记录仍可通过 AuthorizerRefreshFailure 查询
+func (self *Refresher) due(authorizerAppId string, leeway time.Duration) bool {
+	if !self.client.authorizerTokenDue(authorizerAppId, leeway) {
+		return false
+	}
+	failure, err := self.client.AuthorizerRefreshFailure(authorizerAppId)
+	if err == nil && failure.LastError == ErrRefreshTokenNotFound.Error() && self.client.refreshToken(authorizerAppId) == "" {
+		return false
+	}
+	return true
+}
+
+func (self *Refresher) fail(authorizerAppId string, err error) {
+	for _, handler := range self.errors {
+		handler(authorizerAppId, err)
+	}
+}
+
+// AuthorizerTokenExpiresAt 缓存的授权方令牌过期时间, 未缓存时返回 core.ErrCacheMiss
+func (self *Client) AuthorizerTokenExpiresAt(authorizerAppId string) (time.Time, error) {
+	var cached authorizerTokenCache
+	err := self.getCache(AuthorizerTokenCacheKeyPrefix+authorizerAppId, &cached)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(cached.ExpiresIn, 0), nil
+}
+
+// ListAuthorizers 已保存刷新令牌的授权方appid.
+// 未设置 Store 时列表保存在缓存的集合中, 升级前授权且之后未刷新过令牌的授权方需要通过 AddAuthorizers 登记
+func (self *Client) ListAuthorizers() ([]string, error) {
+	return self.ListAuthorizersCtx(context.Background())
+}
+
+// ListAuthorizersCtx 已保存刷新令牌的授权方appid, 设置了 Store 时从中读取
+func (self *Client) ListAuthorizersCtx(ctx context.Context) ([]string, error) {
+	if self.Store != nil {
+		records, err := self.Store.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		authorizerAppIds := make([]string, 0, len(records))
+		for _, record := range records {
+			authorizerAppIds = append(authorizerAppIds, record.AuthorizerAppid)
+		}
+		return authorizerAppIds, nil
+	}
+	return self.Cache.SMembers(AuthorizerListCacheKeyPrefix + self.AppId)
+}
+
+// AddAuthorizers 登记升级前已授权的授权方, 之后由 Refresher 刷新令牌, 重复登记不影响.
+// 授权方列表在保存刷新令牌时登记, 升级后部署一次即可, 缓存与 Store 中都没有刷新令牌时返回 ErrRefreshTokenNotFound
+func (self *Client) AddAuthorizers(authorizerAppIds ...string) error {
+	return self.AddAuthorizersCtx(context.Background(), authorizerAppIds...)
+}
+
+// AddAuthorizersCtx 登记升级前已授权的授权方, 设置了 Store 时将缓存中的刷新令牌写入 Store
+func (self *Client) AddAuthorizersCtx(ctx context.Context, authorizerAppIds ...string) error {
+	for _, authorizerAppId := range authorizerAppIds {
+		refreshToken := self.refreshToken(authorizerAppId)
+		if refreshToken == "" {
+			return fmt.Errorf("%s: %w", authorizerAppId, ErrRefreshTokenNotFound)
+		}
+		var err error
+		if self.Store != nil {
+			err = self.saveStoredRefreshToken(ctx, authorizerAppId, refreshToken)
+		} else {
+			err = self.addAuthorizer(authorizerAppId)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addAuthorizer 登记授权方appid, 设置了 Store 时由 Store 维护列表
+func (self *Client) addAuthorizer(authorizerAppId string) error {
+	if self.Store != nil {
+		return nil
+	}
+	return self.Cache.SAdd(AuthorizerListCacheKeyPrefix+self.AppId, authorizerAppId)
+}
+
+// removeAuthorizer 从授权方列表中移除, 设置了 Store 时从 Store 中删除
+func (self *Client) removeAuthorizer(authorizerAppId string) error {
+	if self.Store != nil {
+		return self.Store.Delete(context.Background(), authorizerAppId)
+	}
+	return self.Cache.SRem(AuthorizerListCacheKeyPrefix+self.AppId, authorizerAppId)
+}
@@ -0,0 +1,166 @@
+package open
+
+import (
+	"context"
+	"errors"
+	"github.com/mrwangjinjin/go-wechat/core"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClients 共用同一缓存与接口地址的多个实例, 返回的函数关闭接口服务
+func newTestClients(n int, handler http.HandlerFunc) ([]*Client, func()) {
+	server := httptest.NewServer(handler)
+	cache := core.NewMemoryCache()
+	clients := make([]*Client, n)
+	for i := range clients {
+		clients[i] = NewClient(&core.ClientConfig{AppId: "wx_component", BaseUrl: server.URL}, cache)
+	}
+	_ = cache.SetEx(ComponentTokenCacheKeyPrefix+"wx_component", &componentTokenCache{
+		ComponentAccessToken: "component_token",
+		ExpiresIn:            time.Now().Unix() + 3600,
+	}, 3600)
+	return clients, server.Close
+}
+
+func TestRefresherRefreshesOnceAcrossReplicas(t *testing.T) {
+	var calls int32
+	clients, cleanup := newTestClients(4, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"authorizer_access_token":"access","expires_in":7200,"authorizer_refresh_token":"refresh2"}`))
+	})
+	defer cleanup()
+	clients[0].saveRefreshToken("wx_authorizer", "refresh1")
+
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		refresher := NewRefresher(client)
+		refresher.Jitter = 0
+		refresher.OnError(func(authorizerAppId string, err error) {
+			t.Errorf("%s: %v", authorizerAppId, err)
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = refresher.RefreshOnce(context.Background())
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("expected 1 refresh across replicas, got %d", calls)
+	}
+
+	// 令牌未到刷新时间时不刷新
+	err := NewRefresher(clients[0]).RefreshOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Fatalf("refreshed a fresh token, calls=%d", calls)
+	}
+}
+
+func TestRefresherSkipsAuthorizerWithoutRefreshToken(t *testing.T) {
+	clients, cleanup := newTestClients(1, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected request")
+	})
+	defer cleanup()
+	client := clients[0]
+	err := client.addAuthorizer("wx_authorizer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	failures := 0
+	refresher := NewRefresher(client)
+	refresher.OnError(func(authorizerAppId string, err error) {
+		failures++
+	})
+	for i := 0; i < 3; i++ {
+		_ = refresher.RefreshOnce(context.Background())
+	}
+	if failures != 1 {
+		t.Fatalf("expected 1 notification for missing refresh token, got %d", failures)
+	}
+
+	// 重新授权后恢复刷新
+	client.saveRefreshToken("wx_authorizer", "refresh1")
+	if !refresher.due("wx_authorizer", refresher.Leeway) {
+		t.Fatal("authorizer not refreshed after a new refresh token was saved")
+	}
+}
+
+func TestRefresherRunWithoutInterval(t *testing.T) {
+	clients, cleanup := newTestClients(1, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected request")
+	})
+	defer cleanup()
+	refresher := &Refresher{
+		client:      clients[0],
+		Authorizers: clients[0].ListAuthorizersCtx,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := refresher.Run(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected Run to stop with the context, got %v", err)
+	}
+}
+
+func TestListAuthorizersAcrossReplicas(t *testing.T) {
+	clients, cleanup := newTestClients(8, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected request")
+	})
+	defer cleanup()
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(1)
+		go func(i int, client *Client) {
+			defer wg.Done()
+			client.saveRefreshToken("wx_authorizer"+strconv.Itoa(i), "refresh")
+		}(i, client)
+	}
+	wg.Wait()
+	authorizerAppIds, err := clients[0].ListAuthorizers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(authorizerAppIds) != len(clients) {
+		t.Fatalf("expected %d authorizers, got %v", len(clients), authorizerAppIds)
+	}
+}
+
+func TestAddAuthorizers(t *testing.T) {
+	for _, store := range []AuthorizerStore{nil, NewMemoryAuthorizerStore()} {
+		clients, cleanup := newTestClients(1, func(w http.ResponseWriter, r *http.Request) {
+			t.Error("unexpected request")
+		})
+		defer cleanup()
+		client := clients[0]
+		client.Store = store
+		// 升级前授权的授权方只有缓存中的刷新令牌
+		_ = client.Cache.Set(AuthorizerRefreshTokenCacheKeyPrefix+"wx_authorizer", "refresh")
+
+		err := client.AddAuthorizers("wx_authorizer", "wx_authorizer")
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorizerAppIds, err := client.ListAuthorizers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(authorizerAppIds) != 1 || authorizerAppIds[0] != "wx_authorizer" {
+			t.Fatalf("store=%T: expected [wx_authorizer], got %v", store, authorizerAppIds)
+		}
+
+		err = client.AddAuthorizers("wx_unknown")
+		if !errors.Is(err, ErrRefreshTokenNotFound) {
+			t.Fatalf("store=%T: expected ErrRefreshTokenNotFound, got %v", store, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package open
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	ComponentTokenFailureCacheKeyPrefix    = "CACHE_COMPONENT_TOKEN_FAILURE@@"
+	AuthorizerRefreshFailureCacheKeyPrefix = "CACHE_AUTHORIZER_REFRESH_FAILURE@@"
+)
+
+// RefreshFailure 令牌连续刷新失败的记录, 刷新成功后清除
+type RefreshFailure struct {
+	Count     int    `json:"count"`
+	LastError string `json:"last_error"`
+	FailedAt  int64  `json:"failed_at"`
+}
+
+// ComponentTokenExpiresAt 缓存的 component_access_token 过期时间, 未缓存时返回 core.ErrCacheMiss
+func (self *Client) ComponentTokenExpiresAt() (time.Time, error) {
+	var cached componentTokenCache
+	err := self.getCache(ComponentTokenCacheKeyPrefix+self.AppId, &cached)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(cached.ExpiresIn, 0), nil
+}
+
+// ComponentTokenFailure component_access_token 连续获取失败的记录, 没有失败时返回 core.ErrCacheMiss
+func (self *Client) ComponentTokenFailure() (*RefreshFailure, error) {
+	return self.refreshFailure(ComponentTokenFailureCacheKeyPrefix + self.AppId)
+}
+
+// AuthorizerRefreshFailure 授权方令牌连续刷新失败的记录, 没有失败时返回 core.ErrCacheMiss
+func (self *Client) AuthorizerRefreshFailure(authorizerAppId string) (*RefreshFailure, error) {
+	return self.refreshFailure(AuthorizerRefreshFailureCacheKeyPrefix + authorizerAppId)
+}
+
+func (self *Client) refreshFailure(key string) (*RefreshFailure, error) {
+	var failure RefreshFailure
+	err := self.getCache(key, &failure)
+	if err != nil {
+		return nil, err
+	}
+	return &failure, nil
+}
+
+// recordRefreshFailure 累加失败次数, err 为 nil 时清除记录, 调用方取消的请求不计入
+func (self *Client) recordRefreshFailure(key string, err error) {
+	if err == nil {
+		if self.Cache.Exists(key) {
+			_ = self.Cache.Delete(key)
+		}
+		return
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	failure, getErr := self.refreshFailure(key)
+	if getErr != nil {
+		failure = &RefreshFailure{}
+	}
+	failure.Count++
+	failure.LastError = err.Error()
+	failure.FailedAt = time.Now().Unix()
+	setErr := self.Cache.Set(key, failure)
+	if setErr != nil {
+		log.Println(setErr)
+	}
+}
@@ -0,0 +1,147 @@
+package open
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrAuthorizerNotFound = errors.New("授权方不存在")
+
+// AuthorizerRecord 持久化的授权方, 刷新令牌丢失后需要授权方重新扫码授权
+type AuthorizerRecord struct {
+	AuthorizerAppid string          `json:"authorizer_appid"`
+	RefreshToken    string          `json:"refresh_token"`
+	FuncInfo        []FuncInfo      `json:"func_info,omitempty"`
+	AuthorizerInfo  *AuthorizerInfo `json:"authorizer_info,omitempty"`
+	CreatedAt       int64           `json:"created_at"`
+	UpdatedAt       int64           `json:"updated_at"`
+}
+
+// AuthorizerStore 授权方存储, 设置到 Client.Store 后作为刷新令牌与授权方列表的持久来源,
+// 缓存被清空或过期时从中恢复. Get、UpdateRefreshToken 在授权方不存在时返回 ErrAuthorizerNotFound
+type AuthorizerStore interface {
+	// Save 新增或覆盖授权方, 已存在时保留 CreatedAt
+	Save(ctx context.Context, record *AuthorizerRecord) error
+	Get(ctx context.Context, authorizerAppId string) (*AuthorizerRecord, error)
+	List(ctx context.Context) ([]*AuthorizerRecord, error)
+	// Delete 删除授权方, 不存在时不返回错误
+	Delete(ctx context.Context, authorizerAppId string) error
+	UpdateRefreshToken(ctx context.Context, authorizerAppId, refreshToken string) error
+}
+
+// MemoryAuthorizerStore 进程内的授权方存储, 重启后丢失, 用于测试或单实例部署
+type MemoryAuthorizerStore struct {
+	mu      sync.RWMutex
+	records map[string]*AuthorizerRecord
+}
+
+func NewMemoryAuthorizerStore() *MemoryAuthorizerStore {
+	return &MemoryAuthorizerStore{
+		records: make(map[string]*AuthorizerRecord),
+	}
+}
+
+func (self *MemoryAuthorizerStore) Save(ctx context.Context, record *AuthorizerRecord) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	saved := copyAuthorizerRecord(record)
+	saved.UpdatedAt = time.Now().Unix()
+	if existing, ok := self.records[record.AuthorizerAppid]; ok {
+		saved.CreatedAt = existing.CreatedAt
+	} else if saved.CreatedAt == 0 {
+		saved.CreatedAt = saved.UpdatedAt
+	}
+	self.records[record.AuthorizerAppid] = saved
+	return nil
+}
+
+func (self *MemoryAuthorizerStore) Get(ctx context.Context, authorizerAppId string) (*AuthorizerRecord, error) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	record, ok := self.records[authorizerAppId]
+	if !ok {
+		return nil, ErrAuthorizerNotFound
+	}
+	return copyAuthorizerRecord(record), nil
+}
+
+func (self *MemoryAuthorizerStore) List(ctx context.Context) ([]*AuthorizerRecord, error) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	records := make([]*AuthorizerRecord, 0, len(self.records))
+	for _, record := range self.records {
+		records = append(records, copyAuthorizerRecord(record))
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].AuthorizerAppid < records[j].AuthorizerAppid
+	})
+	return records, nil
+}
+
+func (self *MemoryAuthorizerStore) Delete(ctx context.Context, authorizerAppId string) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	delete(self.records, authorizerAppId)
+	return nil
+}
+
+func (self *MemoryAuthorizerStore) UpdateRefreshToken(ctx context.Context, authorizerAppId, refreshToken string) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	record, ok := self.records[authorizerAppId]
+	if !ok {
+		return ErrAuthorizerNotFound
+	}
+	record.RefreshToken = refreshToken
+	record.UpdatedAt = time.Now().Unix()
+	return nil
+}
+
+// copyAuthorizerRecord 复制授权方, 避免调用方修改存储中的数据
+func copyAuthorizerRecord(record *AuthorizerRecord) *AuthorizerRecord {
+	copied := *record
+	if record.FuncInfo != nil {
+		copied.FuncInfo = append([]FuncInfo(nil), record.FuncInfo...)
+	}
+	if record.AuthorizerInfo != nil {
+		info := *record.AuthorizerInfo
+		copied.AuthorizerInfo = &info
+	}
+	return &copied
+}
+
+// saveStoredRefreshToken 更新存储中的刷新令牌, 授权方不存在时新增
+func (self *Client) saveStoredRefreshToken(ctx context.Context, authorizerAppId, refreshToken string) error {
+	err := self.Store.UpdateRefreshToken(ctx, authorizerAppId, refreshToken)
+	if !errors.Is(err, ErrAuthorizerNotFound) {
+		return err
+	}
+	return self.Store.Save(ctx, &AuthorizerRecord{
+		AuthorizerAppid: authorizerAppId,
+		RefreshToken:    refreshToken,
+	})
+}
+
+// updateStoredAuthorizer 读取存储中的授权方, 修改后保存, 未设置 Store 时不处理
+func (self *Client) updateStoredAuthorizer(ctx context.Context, authorizerAppId string, update func(record *AuthorizerRecord)) {
+	if self.Store == nil {
+		return
+	}
+	record, err := self.Store.Get(ctx, authorizerAppId)
+	if errors.Is(err, ErrAuthorizerNotFound) {
+		record, err = &AuthorizerRecord{AuthorizerAppid: authorizerAppId}, nil
+	}
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	update(record)
+	err = self.Store.Save(ctx, record)
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,240 @@
+package open
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultAuthorizerTable = "wechat_authorizer"
+)
+
+// AuthorizerTableSchema 授权方表结构, %s 为表名, SQLite、MySQL 与 PostgreSQL 均可直接执行.
+// func_info 与 authorizer_info 以JSON保存
+const AuthorizerTableSchema = `CREATE TABLE IF NOT EXISTS %s (
+	component_appid VARCHAR(64) NOT NULL,
+	authorizer_appid VARCHAR(64) NOT NULL,
+	refresh_token VARCHAR(512) NOT NULL,
+	func_info TEXT,
+	authorizer_info TEXT,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT NOT NULL,
+	PRIMARY KEY (component_appid, authorizer_appid)
+)`
+
+// SQLAuthorizerStore 基于 database/sql 的授权方存储, 驱动由调用方导入,
+// 多个第三方平台可共用一张表, 按 ComponentAppId 区分
+type SQLAuthorizerStore struct {
+	DB             *sql.DB
+	Table          string
+	ComponentAppId string
+	// Placeholder 第n个参数(从1开始)的占位符, 为空时使用 ?, PostgreSQL 使用 DollarPlaceholder
+	Placeholder func(n int) string
+}
+
+func NewSQLAuthorizerStore(db *sql.DB, componentAppId string) *SQLAuthorizerStore {
+	return &SQLAuthorizerStore{
+		DB:             db,
+		Table:          DefaultAuthorizerTable,
+		ComponentAppId: componentAppId,
+	}
+}
+
+// DollarPlaceholder PostgreSQL 的 $1, $2 占位符
+func DollarPlaceholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+// CreateTable 按 AuthorizerTableSchema 建表, 表已存在时不处理
+func (self *SQLAuthorizerStore) CreateTable(ctx context.Context) error {
+	_, err := self.DB.ExecContext(ctx, fmt.Sprintf(AuthorizerTableSchema, self.Table))
+	return err
+}
+
+// Save 在事务中查询后更新或插入, 不依赖各数据库不同的 upsert 语法.
+// 多个实例同时插入同一授权方时主键冲突, 确认已被插入后重试一次更新
+func (self *SQLAuthorizerStore) Save(ctx context.Context, record *AuthorizerRecord) error {
+	funcInfo, err := json.Marshal(record.FuncInfo)
+	if err != nil {
+		return err
+	}
+	var authorizerInfo []byte
+	if record.AuthorizerInfo != nil {
+		authorizerInfo, err = json.Marshal(record.AuthorizerInfo)
+		if err != nil {
+			return err
+		}
+	}
+	inserting, err := self.save(ctx, record, string(funcInfo), nullString(authorizerInfo))
+	if err == nil || !inserting {
+		return err
+	}
+	exists, existsErr := self.exists(ctx, record.AuthorizerAppid)
+	if existsErr != nil || !exists {
+		return err
+	}
+	_, err = self.save(ctx, record, string(funcInfo), nullString(authorizerInfo))
+	return err
+}
+
+// save 不存在时插入, 存在时更新, inserting 表示是否执行了插入
+func (self *SQLAuthorizerStore) save(ctx context.Context, record *AuthorizerRecord, funcInfo string, authorizerInfo sql.NullString) (inserting bool, err error) {
+	now := time.Now().Unix()
+	tx, err := self.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	var createdAt int64
+	err = tx.QueryRowContext(ctx, self.query("SELECT created_at FROM %s WHERE component_appid = ? AND authorizer_appid = ?"),
+		self.ComponentAppId, record.AuthorizerAppid).Scan(&createdAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		inserting = true
+		createdAt = record.CreatedAt
+		if createdAt == 0 {
+			createdAt = now
+		}
+		_, err = tx.ExecContext(ctx, self.query("INSERT INTO %s (component_appid, authorizer_appid, refresh_token, func_info, authorizer_info, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)"),
+			self.ComponentAppId, record.AuthorizerAppid, record.RefreshToken, funcInfo, authorizerInfo, createdAt, now)
+	case err == nil:
+		_, err = tx.ExecContext(ctx, self.query("UPDATE %s SET refresh_token = ?, func_info = ?, authorizer_info = ?, updated_at = ? WHERE component_appid = ? AND authorizer_appid = ?"),
+			record.RefreshToken, funcInfo, authorizerInfo, now, self.ComponentAppId, record.AuthorizerAppid)
+	}
+	if err != nil {
+		return inserting, err
+	}
+	return inserting, tx.Commit()
+}
+
+// exists 授权方是否已存在
+func (self *SQLAuthorizerStore) exists(ctx context.Context, authorizerAppId string) (bool, error) {
+	var exists int
+	err := self.DB.QueryRowContext(ctx, self.query("SELECT 1 FROM %s WHERE component_appid = ? AND authorizer_appid = ?"),
+		self.ComponentAppId, authorizerAppId).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (self *SQLAuthorizerStore) Get(ctx context.Context, authorizerAppId string) (*AuthorizerRecord, error) {
+	row := self.DB.QueryRowContext(ctx, self.query("SELECT authorizer_appid, refresh_token, func_info, authorizer_info, created_at, updated_at FROM %s WHERE component_appid = ? AND authorizer_appid = ?"),
+		self.ComponentAppId, authorizerAppId)
+	record, err := scanAuthorizerRecord(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAuthorizerNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (self *SQLAuthorizerStore) List(ctx context.Context) ([]*AuthorizerRecord, error) {
+	rows, err := self.DB.QueryContext(ctx, self.query("SELECT authorizer_appid, refresh_token, func_info, authorizer_info, created_at, updated_at FROM %s WHERE component_appid = ? ORDER BY authorizer_appid"),
+		self.ComponentAppId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var records []*AuthorizerRecord
+	for rows.Next() {
+		record, err := scanAuthorizerRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func (self *SQLAuthorizerStore) Delete(ctx context.Context, authorizerAppId string) error {
+	_, err := self.DB.ExecContext(ctx, self.query("DELETE FROM %s WHERE component_appid = ? AND authorizer_appid = ?"),
+		self.ComponentAppId, authorizerAppId)
+	return err
+}
+
+func (self *SQLAuthorizerStore) UpdateRefreshToken(ctx context.Context, authorizerAppId, refreshToken string) error {
+	result, err := self.DB.ExecContext(ctx, self.query("UPDATE %s SET refresh_token = ?, updated_at = ? WHERE component_appid = ? AND authorizer_appid = ?"),
+		refreshToken, time.Now().Unix(), self.ComponentAppId, authorizerAppId)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected > 0 {
+		return err
+	}
+	// MySQL 在值未变化时返回0行, 需要再确认授权方是否存在
+	exists, err := self.exists(ctx, authorizerAppId)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrAuthorizerNotFound
+	}
+	return nil
+}
+
+// query 填入表名并按 Placeholder 替换 ? 占位符
+func (self *SQLAuthorizerStore) query(format string) string {
+	query := fmt.Sprintf(format, self.Table)
+	if self.Placeholder == nil {
+		return query
+	}
+	var builder strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			builder.WriteString(self.Placeholder(n))
+			continue
+		}
+		builder.WriteRune(c)
+	}
+	return builder.String()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAuthorizerRecord(row rowScanner) (*AuthorizerRecord, error) {
+	var record AuthorizerRecord
+	var funcInfo, authorizerInfo sql.NullString
+	err := row.Scan(&record.AuthorizerAppid, &record.RefreshToken, &funcInfo, &authorizerInfo, &record.CreatedAt, &record.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if funcInfo.Valid && funcInfo.String != "" {
+		err = json.Unmarshal([]byte(funcInfo.String), &record.FuncInfo)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if authorizerInfo.Valid && authorizerInfo.String != "" {
+		record.AuthorizerInfo = &AuthorizerInfo{}
+		err = json.Unmarshal([]byte(authorizerInfo.String), record.AuthorizerInfo)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &record, nil
+}
+
+// nullString 空内容保存为 NULL
+func nullString(buf []byte) sql.NullString {
+	return sql.NullString{
+		String: string(buf),
+		Valid:  len(buf) > 0,
+	}
+}
@@ -0,0 +1,26 @@
+package open
+
+import (
+	"testing"
+)
+
+func TestSQLAuthorizerStoreQuery(t *testing.T) {
+	tests := []struct {
+		name        string
+		placeholder func(n int) string
+		want        string
+	}{
+		{"default", nil, "UPDATE wechat_authorizer SET refresh_token = ?, updated_at = ? WHERE component_appid = ? AND authorizer_appid = ?"},
+		{"dollar", DollarPlaceholder, "UPDATE wechat_authorizer SET refresh_token = $1, updated_at = $2 WHERE component_appid = $3 AND authorizer_appid = $4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewSQLAuthorizerStore(nil, "wx_component")
+			store.Placeholder = tt.placeholder
+			got := store.query("UPDATE %s SET refresh_token = ?, updated_at = ? WHERE component_appid = ? AND authorizer_appid = ?")
+			if got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package open_test
+
+import (
+	"github.com/mrwangjinjin/go-wechat/core/open"
+	"github.com/mrwangjinjin/go-wechat/core/open/storetest"
+	"testing"
+)
+
+func TestMemoryAuthorizerStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) open.AuthorizerStore {
+		return open.NewMemoryAuthorizerStore()
+	})
+}
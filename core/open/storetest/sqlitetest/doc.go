@@ -0,0 +1,3 @@
+// Package sqlitetest 使用 SQLite 对 open.SQLAuthorizerStore 执行 storetest 一致性测试,
+// 独立为 module 以免根 module 依赖 SQLite 驱动
+package sqlitetest
@@ -0,0 +1,79 @@
+package sqlitetest
+
+import (
+	"context"
+	"database/sql"
+	"github.com/mrwangjinjin/go-wechat/core/open"
+	"github.com/mrwangjinjin/go-wechat/core/open/storetest"
+	_ "modernc.org/sqlite"
+	"path/filepath"
+	"testing"
+)
+
+func newStore(t *testing.T, componentAppId string) *open.SQLAuthorizerStore {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "authorizer.db")+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	// SQLite 同一时间只允许一个写事务
+	db.SetMaxOpenConns(1)
+	store := open.NewSQLAuthorizerStore(db, componentAppId)
+	err = store.CreateTable(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestSQLAuthorizerStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) open.AuthorizerStore {
+		return newStore(t, "wx_component")
+	})
+}
+
+func TestSQLAuthorizerStoreDollarPlaceholder(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) open.AuthorizerStore {
+		store := newStore(t, "wx_component")
+		store.Placeholder = open.DollarPlaceholder
+		return store
+	})
+}
+
+// 多个第三方平台共用一张表时互不影响
+func TestSQLAuthorizerStoreComponentIsolation(t *testing.T) {
+	ctx := context.Background()
+	first := newStore(t, "wx_component_a")
+	second := open.NewSQLAuthorizerStore(first.DB, "wx_component_b")
+
+	err := first.Save(ctx, &open.AuthorizerRecord{AuthorizerAppid: "wx0001", RefreshToken: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = second.Save(ctx, &open.AuthorizerRecord{AuthorizerAppid: "wx0001", RefreshToken: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		store *open.SQLAuthorizerStore
+		want  string
+	}{{first, "a"}, {second, "b"}} {
+		record, err := tt.store.Get(ctx, "wx0001")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.RefreshToken != tt.want {
+			t.Fatalf("%s: expected %s, got %s", tt.store.ComponentAppId, tt.want, record.RefreshToken)
+		}
+	}
+	err = second.Delete(ctx, "wx0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = first.Get(ctx, "wx0001")
+	if err != nil {
+		t.Fatalf("delete leaked across components: %v", err)
+	}
+}
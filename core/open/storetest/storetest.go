@@ -0,0 +1,233 @@
+// Package storetest 对 open.AuthorizerStore 的实现执行一致性测试
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mrwangjinjin/go-wechat/core/open"
+	"sync"
+	"testing"
+)
+
+// Run 执行一致性测试, newStore 每次返回一个空的存储
+func Run(t *testing.T, newStore func(t *testing.T) open.AuthorizerStore) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, store open.AuthorizerStore)
+	}{
+		{"SaveGet", testSaveGet},
+		{"GetNotFound", testGetNotFound},
+		{"SaveOverwrite", testSaveOverwrite},
+		{"List", testList},
+		{"Delete", testDelete},
+		{"UpdateRefreshToken", testUpdateRefreshToken},
+		{"UpdateRefreshTokenNotFound", testUpdateRefreshTokenNotFound},
+		{"ConcurrentSave", testConcurrentSave},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+func newRecord(authorizerAppId string) *open.AuthorizerRecord {
+	return &open.AuthorizerRecord{
+		AuthorizerAppid: authorizerAppId,
+		RefreshToken:    "refreshtoken@@@" + authorizerAppId,
+		FuncInfo: []open.FuncInfo{
+			{FuncscopeCategory: open.FuncScopeCategory{Id: 17}},
+			{FuncscopeCategory: open.FuncScopeCategory{Id: 18}},
+		},
+		AuthorizerInfo: &open.AuthorizerInfo{
+			NickName: "小程序" + authorizerAppId,
+			UserName: "gh_" + authorizerAppId,
+		},
+	}
+}
+
+func testSaveGet(t *testing.T, store open.AuthorizerStore) {
+	ctx := context.Background()
+	record := newRecord("wx0001")
+	err := store.Save(ctx, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Get(ctx, "wx0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AuthorizerAppid != "wx0001" || got.RefreshToken != record.RefreshToken {
+		t.Fatalf("unexpected record %+v", got)
+	}
+	if len(got.FuncInfo) != 2 || got.FuncInfo[1].FuncscopeCategory.Id != 18 {
+		t.Fatalf("unexpected func_info %+v", got.FuncInfo)
+	}
+	if got.AuthorizerInfo == nil || got.AuthorizerInfo.UserName != "gh_wx0001" {
+		t.Fatalf("unexpected authorizer_info %+v", got.AuthorizerInfo)
+	}
+	if got.CreatedAt == 0 || got.UpdatedAt == 0 {
+		t.Fatalf("timestamps not set %+v", got)
+	}
+
+	// 修改返回值不影响存储
+	got.FuncInfo[0].FuncscopeCategory.Id = 1
+	again, err := store.Get(ctx, "wx0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.FuncInfo[0].FuncscopeCategory.Id != 17 {
+		t.Fatal("store returned a shared record")
+	}
+}
+
+func testGetNotFound(t *testing.T, store open.AuthorizerStore) {
+	_, err := store.Get(context.Background(), "wx_missing")
+	if !errors.Is(err, open.ErrAuthorizerNotFound) {
+		t.Fatalf("expected ErrAuthorizerNotFound, got %v", err)
+	}
+}
+
+func testSaveOverwrite(t *testing.T, store open.AuthorizerStore) {
+	ctx := context.Background()
+	record := newRecord("wx0001")
+	record.CreatedAt = 1600000000
+	err := store.Save(ctx, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := newRecord("wx0001")
+	updated.RefreshToken = "new"
+	updated.FuncInfo = nil
+	updated.AuthorizerInfo = nil
+	err = store.Save(ctx, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Get(ctx, "wx0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.RefreshToken != "new" || len(got.FuncInfo) != 0 || got.AuthorizerInfo != nil {
+		t.Fatalf("record not overwritten %+v", got)
+	}
+	if got.CreatedAt != 1600000000 {
+		t.Fatalf("CreatedAt not preserved, got %d", got.CreatedAt)
+	}
+}
+
+func testList(t *testing.T, store open.AuthorizerStore) {
+	ctx := context.Background()
+	records, err := store.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("expected empty store, got %d", len(records))
+	}
+	for _, authorizerAppId := range []string{"wx0003", "wx0001", "wx0002"} {
+		err := store.Save(ctx, newRecord(authorizerAppId))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err = store.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	for i, authorizerAppId := range []string{"wx0001", "wx0002", "wx0003"} {
+		if records[i].AuthorizerAppid != authorizerAppId {
+			t.Fatalf("records[%d] = %s, want %s", i, records[i].AuthorizerAppid, authorizerAppId)
+		}
+		if len(records[i].FuncInfo) != 2 {
+			t.Fatalf("records[%d] func_info not loaded", i)
+		}
+	}
+}
+
+func testDelete(t *testing.T, store open.AuthorizerStore) {
+	ctx := context.Background()
+	err := store.Save(ctx, newRecord("wx0001"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Delete(ctx, "wx0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.Get(ctx, "wx0001")
+	if !errors.Is(err, open.ErrAuthorizerNotFound) {
+		t.Fatalf("expected ErrAuthorizerNotFound after delete, got %v", err)
+	}
+	err = store.Delete(ctx, "wx0001")
+	if err != nil {
+		t.Fatalf("deleting a missing authorizer: %v", err)
+	}
+}
+
+func testUpdateRefreshToken(t *testing.T, store open.AuthorizerStore) {
+	ctx := context.Background()
+	err := store.Save(ctx, newRecord("wx0001"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.UpdateRefreshToken(ctx, "wx0001", "rotated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 值未变化时不能返回 ErrAuthorizerNotFound
+	err = store.UpdateRefreshToken(ctx, "wx0001", "rotated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Get(ctx, "wx0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.RefreshToken != "rotated" {
+		t.Fatalf("expected rotated, got %s", got.RefreshToken)
+	}
+	if len(got.FuncInfo) != 2 || got.AuthorizerInfo == nil {
+		t.Fatal("UpdateRefreshToken changed other fields")
+	}
+}
+
+func testUpdateRefreshTokenNotFound(t *testing.T, store open.AuthorizerStore) {
+	err := store.UpdateRefreshToken(context.Background(), "wx_missing", "token")
+	if !errors.Is(err, open.ErrAuthorizerNotFound) {
+		t.Fatalf("expected ErrAuthorizerNotFound, got %v", err)
+	}
+}
+
+func testConcurrentSave(t *testing.T, store open.AuthorizerStore) {
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			record := newRecord("wx0001")
+			record.RefreshToken = fmt.Sprintf("token%d", i)
+			errs <- store.Save(ctx, record)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := store.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+}
@@ -0,0 +1,134 @@
+package open
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultTokenRefreshLeeway 令牌过期前提前刷新的时间
+const DefaultTokenRefreshLeeway = 5 * time.Minute
+
+const (
+	AuthorizerTokenLockCacheKeyPrefix = "CACHE_AUTHORIZER_LOCK@@"
+)
+
+var ErrRefreshTokenNotFound = errors.New("授权方刷新令牌不存在,需要重新授权")
+
+// AuthorizerTokenSource 按授权方appid提供有效的 authorizer_access_token, 过期前自动刷新
+type AuthorizerTokenSource struct {
+	client          *Client
+	authorizerAppId string
+	mu              sync.Mutex
+	Leeway          time.Duration
+}
+
+// NewAuthorizerTokenSource
+func NewAuthorizerTokenSource(client *Client, authorizerAppId string) *AuthorizerTokenSource {
+	return &AuthorizerTokenSource{
+		client:          client,
+		authorizerAppId: authorizerAppId,
+		Leeway:          DefaultTokenRefreshLeeway,
+	}
+}
+
+// Token 获取有效的 authorizer_access_token
+func (self *AuthorizerTokenSource) Token() (string, error) {
+	return self.TokenCtx(context.Background())
+}
+
+// TokenCtx 获取有效的 authorizer_access_token
+func (self *AuthorizerTokenSource) TokenCtx(ctx context.Context) (string, error) {
+	token, ok := self.cachedToken()
+	if ok {
+		return token, nil
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	// 等待期间可能已被其他调用刷新
+	token, ok = self.cachedToken()
+	if ok {
+		return token, nil
+	}
+	return self.refresh(ctx)
+}
+
+// Refresh 强制刷新 authorizer_access_token
+func (self *AuthorizerTokenSource) Refresh(ctx context.Context) (string, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.refresh(ctx)
+}
+
+// refreshIfDue 令牌将在 leeway 内过期时刷新, 多个实例通过缓存锁只有一个刷新,
+// 获取锁后再次检查令牌, 已被其他实例刷新或锁被占用时返回 false
+func (self *AuthorizerTokenSource) refreshIfDue(ctx context.Context, leeway time.Duration) (bool, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if !self.client.authorizerTokenDue(self.authorizerAppId, leeway) {
+		return false, nil
+	}
+	unlock, locked, err := self.client.tryLock(AuthorizerTokenLockCacheKeyPrefix+self.authorizerAppId, self.client.authorizerTokenLockTimeout())
+	if err != nil || !locked {
+		return false, err
+	}
+	defer unlock()
+	if !self.client.authorizerTokenDue(self.authorizerAppId, leeway) {
+		return false, nil
+	}
+	_, err = self.refresh(ctx)
+	return err == nil, err
+}
+
+// Invalidate 将缓存的令牌标记为过期, 下次获取时重新刷新
+func (self *AuthorizerTokenSource) Invalidate() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	_ = self.client.Cache.SetEx(AuthorizerTokenCacheKeyPrefix+self.authorizerAppId, &authorizerTokenCache{
+		AuthorizerRefreshToken: self.client.refreshToken(self.authorizerAppId),
+	}, 6600)
+}
+
+func (self *AuthorizerTokenSource) cachedToken() (string, bool) {
+	var cached authorizerTokenCache
+	err := self.client.getCache(AuthorizerTokenCacheKeyPrefix+self.authorizerAppId, &cached)
+	if err != nil || cached.AuthorizerAccessToken == "" {
+		return "", false
+	}
+	if time.Now().Add(self.Leeway).Unix() >= cached.ExpiresIn {
+		return "", false
+	}
+	return cached.AuthorizerAccessToken, true
+}
+
+func (self *AuthorizerTokenSource) refresh(ctx context.Context) (string, error) {
+	refreshToken := self.client.refreshToken(self.authorizerAppId)
+	if refreshToken == "" {
+		self.client.recordRefreshFailure(AuthorizerRefreshFailureCacheKeyPrefix+self.authorizerAppId, ErrRefreshTokenNotFound)
+		return "", ErrRefreshTokenNotFound
+	}
+	resp, err := self.client.RefreshAuthorizerTokenCtx(ctx, self.authorizerAppId, refreshToken)
+	if err != nil {
+		log.Println(err)
+		return "", err
+	}
+	return resp.AuthorizerAccessToken, nil
+}
+
+// authorizerTokenDue 缓存的令牌不存在或将在 leeway 内过期
+func (self *Client) authorizerTokenDue(authorizerAppId string, leeway time.Duration) bool {
+	expiresAt, err := self.AuthorizerTokenExpiresAt(authorizerAppId)
+	if err != nil {
+		return true
+	}
+	return time.Until(expiresAt) <= leeway
+}
+
+// authorizerTokenLockTimeout 刷新授权方令牌的锁有效期, 覆盖等待 component_access_token 与一次请求的时间
+func (self *Client) authorizerTokenLockTimeout() time.Duration {
+	return self.componentTokenLockTimeout() + self.Http.Timeout()
+}
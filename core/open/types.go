@@ -0,0 +1,353 @@
+package open
+
+// BaseResponse 微信接口通用返回
+type BaseResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// ComponentAccessTokenRequest 获取第三方平台component_access_token
+type ComponentAccessTokenRequest struct {
+	ComponentAppid        string `json:"component_appid"`
+	ComponentAppsecret    string `json:"component_appsecret"`
+	ComponentVerifyTicket string `json:"component_verify_ticket"`
+}
+
+// ComponentAccessToken 第三方平台component_access_token
+type ComponentAccessToken struct {
+	BaseResponse
+	ComponentAccessToken string `json:"component_access_token"`
+	ExpiresIn            int64  `json:"expires_in"`
+}
+
+// componentTokenCache 缓存中的component_access_token, expires_in 为过期时间戳
+type componentTokenCache struct {
+	ComponentAccessToken string `json:"component_access_token"`
+	ExpiresIn            int64  `json:"expires_in"`
+}
+
+// PreAuthCodeRequest 获取预授权码
+type PreAuthCodeRequest struct {
+	ComponentAppid string `json:"component_appid"`
+}
+
+// PreAuthCode 预授权码
+type PreAuthCode struct {
+	BaseResponse
+	PreAuthCode string `json:"pre_auth_code"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// QueryAuthRequest 使用授权码获取授权信息
+type QueryAuthRequest struct {
+	ComponentAppid    string `json:"component_appid"`
+	AuthorizationCode string `json:"authorization_code"`
+}
+
+// FuncScopeCategory 权限集
+type FuncScopeCategory struct {
+	Id int `json:"id"`
+}
+
+// FuncInfo 授权给第三方平台的权限集
+type FuncInfo struct {
+	FuncscopeCategory FuncScopeCategory `json:"funcscope_category"`
+}
+
+// AuthorizationInfo 授权信息
+type AuthorizationInfo struct {
+	AuthorizerAppid        string     `json:"authorizer_appid"`
+	AuthorizerAccessToken  string     `json:"authorizer_access_token"`
+	ExpiresIn              int64      `json:"expires_in"`
+	AuthorizerRefreshToken string     `json:"authorizer_refresh_token"`
+	FuncInfo               []FuncInfo `json:"func_info"`
+}
+
+// QueryAuthResponse 使用授权码获取授权信息
+type QueryAuthResponse struct {
+	BaseResponse
+	AuthorizationInfo AuthorizationInfo `json:"authorization_info"`
+}
+
+// AuthorizerTokenRequest 获取/刷新授权方接口调用令牌
+type AuthorizerTokenRequest struct {
+	ComponentAppid         string `json:"component_appid"`
+	AuthorizerAppid        string `json:"authorizer_appid"`
+	AuthorizerRefreshToken string `json:"authorizer_refresh_token"`
+}
+
+// AuthorizerToken 授权方接口调用令牌
+type AuthorizerToken struct {
+	BaseResponse
+	AuthorizerAccessToken  string `json:"authorizer_access_token"`
+	ExpiresIn              int64  `json:"expires_in"`
+	AuthorizerRefreshToken string `json:"authorizer_refresh_token"`
+}
+
+// authorizerTokenCache 缓存中的授权方令牌, expires_in 为过期时间戳
+type authorizerTokenCache struct {
+	AuthorizerAppid        string     `json:"authorizer_appid,omitempty"`
+	AuthorizerAccessToken  string     `json:"authorizer_access_token"`
+	AuthorizerRefreshToken string     `json:"authorizer_refresh_token"`
+	ExpiresIn              int64      `json:"expires_in"`
+	FuncInfo               []FuncInfo `json:"func_info,omitempty"`
+}
+
+// AuthorizerInfoRequest 获取授权方的帐号基本信息
+type AuthorizerInfoRequest struct {
+	ComponentAppid  string `json:"component_appid"`
+	AuthorizerAppid string `json:"authorizer_appid"`
+}
+
+// TypeInfo 授权方类型信息
+type TypeInfo struct {
+	Id int `json:"id"`
+}
+
+// BusinessInfo 授权方功能开通情况
+type BusinessInfo struct {
+	OpenStore int `json:"open_store"`
+	OpenScan  int `json:"open_scan"`
+	OpenPay   int `json:"open_pay"`
+	OpenCard  int `json:"open_card"`
+	OpenShake int `json:"open_shake"`
+}
+
+// MiniProgramNetwork 小程序服务器域名
+type MiniProgramNetwork struct {
+	RequestDomain   []string `json:"RequestDomain"`
+	WsRequestDomain []string `json:"WsRequestDomain"`
+	UploadDomain    []string `json:"UploadDomain"`
+	DownloadDomain  []string `json:"DownloadDomain"`
+	BizDomain       []string `json:"BizDomain"`
+	UDPDomain       []string `json:"UDPDomain"`
+}
+
+// MiniProgramCategory 小程序类目
+type MiniProgramCategory struct {
+	First  string `json:"first"`
+	Second string `json:"second"`
+}
+
+// MiniProgramInfo 小程序信息
+type MiniProgramInfo struct {
+	Network     MiniProgramNetwork    `json:"network"`
+	Categories  []MiniProgramCategory `json:"categories"`
+	VisitStatus int                   `json:"visit_status"`
+}
+
+// AuthorizerInfo 授权方的帐号基本信息
+type AuthorizerInfo struct {
+	NickName        string           `json:"nick_name"`
+	HeadImg         string           `json:"head_img"`
+	ServiceTypeInfo TypeInfo         `json:"service_type_info"`
+	VerifyTypeInfo  TypeInfo         `json:"verify_type_info"`
+	UserName        string           `json:"user_name"`
+	PrincipalName   string           `json:"principal_name"`
+	Alias           string           `json:"alias"`
+	BusinessInfo    BusinessInfo     `json:"business_info"`
+	QrcodeUrl       string           `json:"qrcode_url"`
+	Signature       string           `json:"signature"`
+	MiniProgramInfo *MiniProgramInfo `json:"MiniProgramInfo,omitempty"`
+}
+
+// AuthorizerInfoResponse 获取授权方的帐号基本信息
+type AuthorizerInfoResponse struct {
+	BaseResponse
+	AuthorizerInfo    AuthorizerInfo    `json:"authorizer_info"`
+	AuthorizationInfo AuthorizationInfo `json:"authorization_info"`
+}
+
+// FastRegisterWeappRequest 快速注册小程序
+type FastRegisterWeappRequest struct {
+	Name               string `json:"name"`
+	Code               string `json:"code"`
+	CodeType           int    `json:"code_type"`
+	LegalPersonaWechat string `json:"legal_persona_wechat"`
+	LegalPersonaName   string `json:"legal_persona_name"`
+	ComponentPhone     string `json:"component_phone,omitempty"`
+}
+
+// FastRegisterWeappSearchRequest 快速注册小程序结果查询
+type FastRegisterWeappSearchRequest struct {
+	Name               string `json:"name"`
+	LegalPersonaWechat string `json:"legal_persona_wechat"`
+	LegalPersonaName   string `json:"legal_persona_name"`
+}
+
+// BindTesterRequest 绑定体验者
+type BindTesterRequest struct {
+	WechatId string `json:"wechatid"`
+}
+
+// BindTesterResponse 绑定体验者
+type BindTesterResponse struct {
+	BaseResponse
+	UserStr string `json:"userstr"`
+}
+
+// UnbindTesterRequest 解除绑定体验者, WechatId 与 UserStr 二选一
+type UnbindTesterRequest struct {
+	WechatId string `json:"wechatid,omitempty"`
+	UserStr  string `json:"userstr,omitempty"`
+}
+
+// ModifyDomainRequest 修改小程序服务器域名
+type ModifyDomainRequest struct {
+	Action          string   `json:"action"`
+	RequestDomain   []string `json:"requestdomain,omitempty"`
+	WsRequestDomain []string `json:"wsrequestdomain,omitempty"`
+	UploadDomain    []string `json:"uploaddomain,omitempty"`
+	DownloadDomain  []string `json:"downloaddomain,omitempty"`
+}
+
+// ModifyDomainResponse 修改小程序服务器域名
+type ModifyDomainResponse struct {
+	BaseResponse
+	RequestDomain   []string `json:"requestdomain"`
+	WsRequestDomain []string `json:"wsrequestdomain"`
+	UploadDomain    []string `json:"uploaddomain"`
+	DownloadDomain  []string `json:"downloaddomain"`
+}
+
+// CommitCodeRequest 上传小程序代码
+type CommitCodeRequest struct {
+	TemplateId  int64  `json:"template_id"`
+	ExtJson     string `json:"ext_json"`
+	UserVersion string `json:"user_version"`
+	UserDesc    string `json:"user_desc"`
+}
+
+// AuditItem 提交审核项
+type AuditItem struct {
+	Address     string `json:"address,omitempty"`
+	Tag         string `json:"tag,omitempty"`
+	FirstClass  string `json:"first_class,omitempty"`
+	SecondClass string `json:"second_class,omitempty"`
+	ThirdClass  string `json:"third_class,omitempty"`
+	FirstId     int    `json:"first_id,omitempty"`
+	SecondId    int    `json:"second_id,omitempty"`
+	ThirdId     int    `json:"third_id,omitempty"`
+	Title       string `json:"title,omitempty"`
+}
+
+// SubmitAuditRequest 提交审核
+type SubmitAuditRequest struct {
+	ItemList      []AuditItem `json:"item_list,omitempty"`
+	FeedbackInfo  string      `json:"feedback_info,omitempty"`
+	FeedbackStuff string      `json:"feedback_stuff,omitempty"`
+	VersionDesc   string      `json:"version_desc,omitempty"`
+}
+
+// SubmitAuditResponse 提交审核
+type SubmitAuditResponse struct {
+	BaseResponse
+	AuditId int64 `json:"auditid"`
+}
+
+// AuditStatus 审核状态, Status 0:审核成功 1:审核被拒绝 2:审核中 3:已撤回
+type AuditStatus struct {
+	BaseResponse
+	AuditId    int64  `json:"auditid"`
+	Status     int    `json:"status"`
+	Reason     string `json:"reason"`
+	ScreenShot string `json:"ScreenShot"`
+}
+
+// LineColor 小程序码线条颜色
+type LineColor struct {
+	R string `json:"r"`
+	G string `json:"g"`
+	B string `json:"b"`
+}
+
+// GetWxaCodeRequest 获取小程序码
+type GetWxaCodeRequest struct {
+	Path      string     `json:"path"`
+	Width     int        `json:"width,omitempty"`
+	AutoColor bool       `json:"auto_color,omitempty"`
+	LineColor *LineColor `json:"line_color,omitempty"`
+	IsHyaline bool       `json:"is_hyaline,omitempty"`
+}
+
+// WxaQrCodeRequest 获取小程序二维码
+type WxaQrCodeRequest struct {
+	Path  string `json:"path"`
+	Width int    `json:"width,omitempty"`
+}
+
+// Template 代码模板
+type Template struct {
+	CreateTime             int64  `json:"create_time"`
+	UserVersion            string `json:"user_version"`
+	UserDesc               string `json:"user_desc"`
+	TemplateId             int64  `json:"template_id"`
+	SourceMiniprogramAppid string `json:"source_miniprogram_appid"`
+	SourceMiniprogram      string `json:"source_miniprogram"`
+	Developer              string `json:"developer"`
+}
+
+// TemplateList 代码模板列表
+type TemplateList struct {
+	BaseResponse
+	TemplateList []Template `json:"template_list"`
+}
+
+// PageList 已上传代码的页面列表
+type PageList struct {
+	BaseResponse
+	PageList []string `json:"page_list"`
+}
+
+// Session 小程序登录凭证
+type Session struct {
+	BaseResponse
+	OpenId     string `json:"openid"`
+	SessionKey string `json:"session_key"`
+	UnionId    string `json:"unionid"`
+}
+
+// MemberAuthRequest 获取体验者列表
+type MemberAuthRequest struct {
+	Action string `json:"action"`
+}
+
+// Tester 体验者
+type Tester struct {
+	UserStr string `json:"userstr"`
+}
+
+// TesterList 体验者列表
+type TesterList struct {
+	BaseResponse
+	Members []Tester `json:"members"`
+}
+
+// OAuth2AccessToken 代公众号网页授权access_token
+type OAuth2AccessToken struct {
+	BaseResponse
+	AccessToken  string `json:"access_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	OpenId       string `json:"openid"`
+	Scope        string `json:"scope"`
+}
+
+// CustomText 客服文本消息内容
+type CustomText struct {
+	Content string `json:"content"`
+}
+
+// CustomMedia 客服图片/语音消息内容
+type CustomMedia struct {
+	MediaId string `json:"media_id"`
+}
+
+// CustomMessage 客服消息
+type CustomMessage struct {
+	ToUser  string       `json:"touser"`
+	MsgType string       `json:"msgtype"`
+	Text    *CustomText  `json:"text,omitempty"`
+	Image   *CustomMedia `json:"image,omitempty"`
+	Voice   *CustomMedia `json:"voice,omitempty"`
+}
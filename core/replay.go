@@ -0,0 +1,62 @@
+package core
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+)
+
+const (
+	NonceCacheKeyPrefix = "CACHE_NONCE@@"
+)
+
+// DefaultReplayWindow 推送 timestamp 与当前时间的最大偏差
+const DefaultReplayWindow = 5 * time.Minute
+
+// errRetried nonce 已被处理过, 为微信重试或重放的推送
+var errRetried = errors.New("重复的推送")
+
+// checkReplay 校验 timestamp 是否在有效范围内, 有效范围内重复使用的 nonce 返回 errRetried.
+// ReplayWindow 小于0时不校验, 缓存不可用时只校验 timestamp
+func (self *Server) checkReplay(timestamp, nonce string) error {
+	if self.ReplayWindow < 0 {
+		return nil
+	}
+	window := self.ReplayWindow
+	if window == 0 {
+		window = DefaultReplayWindow
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return newServeError(ErrReplay, err)
+	}
+	diff := time.Since(time.Unix(ts, 0))
+	if diff > window || diff < -window {
+		return newServeError(ErrReplay, nil)
+	}
+	if self.Cache == nil {
+		return nil
+	}
+	// nonce 在 timestamp 前后 window 内都可能被重放
+	ok, err := self.Cache.SetNX(NonceCacheKeyPrefix+timestamp+":"+nonce, 1, int64(2*window/time.Second))
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	if !ok {
+		return errRetried
+	}
+	return nil
+}
+
+// forgetNonce 删除 nonce 记录, 用于推送未能处理需要微信重试的情况
+func (self *Server) forgetNonce(timestamp, nonce string) {
+	if self.Cache == nil {
+		return
+	}
+	err := self.Cache.Delete(NonceCacheKeyPrefix + timestamp + ":" + nonce)
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,208 @@
+package core
+
+import (
+	"encoding/xml"
+	"reflect"
+	"time"
+)
+
+// 被动回复消息类型
+const (
+	ReplyTypeText                    = "text"
+	ReplyTypeImage                   = "image"
+	ReplyTypeVoice                   = "voice"
+	ReplyTypeVideo                   = "video"
+	ReplyTypeMusic                   = "music"
+	ReplyTypeNews                    = "news"
+	ReplyTypeTransferCustomerService = "transfer_customer_service"
+)
+
+// CDATA 序列化为 <![CDATA[...]]>
+type CDATA string
+
+func (self CDATA) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(struct {
+		Value string `xml:",cdata"`
+	}{string(self)}, start)
+}
+
+// Reply 被动回复消息, 由 NewTextReply 等函数创建
+type Reply interface {
+	header() *ReplyHeader
+}
+
+type ReplyHeader struct {
+	XMLName      xml.Name `xml:"xml"`
+	ToUserName   CDATA    `xml:"ToUserName"`
+	FromUserName CDATA    `xml:"FromUserName"`
+	CreateTime   int64    `xml:"CreateTime"`
+	MsgType      CDATA    `xml:"MsgType"`
+}
+
+func (self *ReplyHeader) header() *ReplyHeader {
+	return self
+}
+
+// TextReply 文本消息
+type TextReply struct {
+	ReplyHeader
+	Content CDATA `xml:"Content"`
+}
+
+type ReplyMedia struct {
+	MediaId CDATA `xml:"MediaId"`
+}
+
+// ImageReply 图片消息
+type ImageReply struct {
+	ReplyHeader
+	Image ReplyMedia `xml:"Image"`
+}
+
+// VoiceReply 语音消息
+type VoiceReply struct {
+	ReplyHeader
+	Voice ReplyMedia `xml:"Voice"`
+}
+
+type ReplyVideo struct {
+	MediaId     CDATA `xml:"MediaId"`
+	Title       CDATA `xml:"Title,omitempty"`
+	Description CDATA `xml:"Description,omitempty"`
+}
+
+// VideoReply 视频消息
+type VideoReply struct {
+	ReplyHeader
+	Video ReplyVideo `xml:"Video"`
+}
+
+type ReplyMusic struct {
+	Title        CDATA `xml:"Title,omitempty"`
+	Description  CDATA `xml:"Description,omitempty"`
+	MusicUrl     CDATA `xml:"MusicUrl,omitempty"`
+	HQMusicUrl   CDATA `xml:"HQMusicUrl,omitempty"`
+	ThumbMediaId CDATA `xml:"ThumbMediaId"`
+}
+
+// MusicReply 音乐消息
+type MusicReply struct {
+	ReplyHeader
+	Music ReplyMusic `xml:"Music"`
+}
+
+type ReplyArticle struct {
+	Title       CDATA `xml:"Title"`
+	Description CDATA `xml:"Description"`
+	PicUrl      CDATA `xml:"PicUrl"`
+	Url         CDATA `xml:"Url"`
+}
+
+// NewsReply 图文消息, 最多8条
+type NewsReply struct {
+	ReplyHeader
+	ArticleCount int            `xml:"ArticleCount"`
+	Articles     []ReplyArticle `xml:"Articles>item"`
+}
+
+type ReplyTransInfo struct {
+	KfAccount CDATA `xml:"KfAccount"`
+}
+
+// TransferCustomerServiceReply 转发到客服, TransInfo 为空时由任意在线客服接入
+type TransferCustomerServiceReply struct {
+	ReplyHeader
+	TransInfo *ReplyTransInfo `xml:"TransInfo,omitempty"`
+}
+
+func NewTextReply(content string) *TextReply {
+	return &TextReply{
+		ReplyHeader: ReplyHeader{MsgType: ReplyTypeText},
+		Content:     CDATA(content),
+	}
+}
+
+func NewImageReply(mediaId string) *ImageReply {
+	return &ImageReply{
+		ReplyHeader: ReplyHeader{MsgType: ReplyTypeImage},
+		Image:       ReplyMedia{MediaId: CDATA(mediaId)},
+	}
+}
+
+func NewVoiceReply(mediaId string) *VoiceReply {
+	return &VoiceReply{
+		ReplyHeader: ReplyHeader{MsgType: ReplyTypeVoice},
+		Voice:       ReplyMedia{MediaId: CDATA(mediaId)},
+	}
+}
+
+func NewVideoReply(mediaId, title, description string) *VideoReply {
+	return &VideoReply{
+		ReplyHeader: ReplyHeader{MsgType: ReplyTypeVideo},
+		Video: ReplyVideo{
+			MediaId:     CDATA(mediaId),
+			Title:       CDATA(title),
+			Description: CDATA(description),
+		},
+	}
+}
+
+func NewMusicReply(title, description, musicUrl, hqMusicUrl, thumbMediaId string) *MusicReply {
+	return &MusicReply{
+		ReplyHeader: ReplyHeader{MsgType: ReplyTypeMusic},
+		Music: ReplyMusic{
+			Title:        CDATA(title),
+			Description:  CDATA(description),
+			MusicUrl:     CDATA(musicUrl),
+			HQMusicUrl:   CDATA(hqMusicUrl),
+			ThumbMediaId: CDATA(thumbMediaId),
+		},
+	}
+}
+
+func NewNewsReply(articles ...ReplyArticle) *NewsReply {
+	return &NewsReply{
+		ReplyHeader:  ReplyHeader{MsgType: ReplyTypeNews},
+		ArticleCount: len(articles),
+		Articles:     articles,
+	}
+}
+
+// NewTransferCustomerServiceReply kfAccount 为空时不指定客服
+func NewTransferCustomerServiceReply(kfAccount string) *TransferCustomerServiceReply {
+	reply := &TransferCustomerServiceReply{
+		ReplyHeader: ReplyHeader{MsgType: ReplyTypeTransferCustomerService},
+	}
+	if kfAccount != "" {
+		reply.TransInfo = &ReplyTransInfo{KfAccount: CDATA(kfAccount)}
+	}
+	return reply
+}
+
+// MarshalReply 以收到的消息填充回复的收发方与时间后序列化, 填充在副本上进行,
+// 同一个 Reply 可以回复给不同用户或在多个推送中同时使用
+func MarshalReply(message *EventMessage, reply Reply) ([]byte, error) {
+	reply = copyReply(reply)
+	header := reply.header()
+	if header.ToUserName == "" {
+		header.ToUserName = CDATA(message.FromUserName)
+	}
+	if header.FromUserName == "" {
+		header.FromUserName = CDATA(message.ToUserName)
+	}
+	if header.CreateTime == 0 {
+		header.CreateTime = time.Now().Unix()
+	}
+	return xml.Marshal(reply)
+}
+
+// copyReply 浅复制回复, Reply 由 *ReplyHeader 实现, 均为指针
+func copyReply(reply Reply) Reply {
+	value := reflect.ValueOf(reply)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return reply
+	}
+	copied := reflect.New(value.Elem().Type())
+	copied.Elem().Set(value.Elem())
+	return copied.Interface().(Reply)
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"encoding/xml"
+	"github.com/mrwangjinjin/go-wechat/pkg/crypto"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMarshalReply(t *testing.T) {
+	message := &EventMessage{EventHeaderMessage: EventHeaderMessage{ToUserName: "gh_1", FromUserName: "o_user"}}
+	tests := []struct {
+		name     string
+		reply    Reply
+		contains []string
+	}{
+		{"text", NewTextReply("hello"), []string{"<MsgType><![CDATA[text]]></MsgType>", "<Content><![CDATA[hello]]></Content>"}},
+		{"image", NewImageReply("media"), []string{"<MsgType><![CDATA[image]]></MsgType>", "<Image><MediaId><![CDATA[media]]></MediaId></Image>"}},
+		{"voice", NewVoiceReply("media"), []string{"<Voice><MediaId><![CDATA[media]]></MediaId></Voice>"}},
+		{"video without title", NewVideoReply("media", "", ""), []string{"<Video><MediaId><![CDATA[media]]></MediaId></Video>"}},
+		{"music", NewMusicReply("title", "", "http://music", "", "thumb"), []string{"<Music><Title><![CDATA[title]]></Title><MusicUrl><![CDATA[http://music]]></MusicUrl><ThumbMediaId><![CDATA[thumb]]></ThumbMediaId></Music>"}},
+		{"news", NewNewsReply(ReplyArticle{Title: "t1", Url: "http://1"}, ReplyArticle{Title: "t2"}), []string{"<ArticleCount>2</ArticleCount>", "<Articles><item><Title><![CDATA[t1]]></Title>"}},
+		{"transfer to any", NewTransferCustomerServiceReply(""), []string{"<MsgType><![CDATA[transfer_customer_service]]></MsgType></xml>"}},
+		{"transfer to account", NewTransferCustomerServiceReply("kf@test"), []string{"<TransInfo><KfAccount><![CDATA[kf@test]]></KfAccount></TransInfo>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf, err := MarshalReply(message, tt.reply)
+			if err != nil {
+				t.Fatal(err)
+			}
+			contains := append([]string{
+				"<ToUserName><![CDATA[o_user]]></ToUserName>",
+				"<FromUserName><![CDATA[gh_1]]></FromUserName>",
+			}, tt.contains...)
+			for _, s := range contains {
+				if !strings.Contains(string(buf), s) {
+					t.Fatalf("%s missing from %s", s, buf)
+				}
+			}
+		})
+	}
+}
+
+func TestMarshalReplyReusedForDifferentUsers(t *testing.T) {
+	welcome := NewTextReply("welcome")
+	for _, user := range []string{"o_a", "o_b"} {
+		message := &EventMessage{EventHeaderMessage: EventHeaderMessage{ToUserName: "gh_1", FromUserName: user}}
+		buf, err := MarshalReply(message, welcome)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(buf), "<ToUserName><![CDATA["+user+"]]></ToUserName>") {
+			t.Fatalf("reply to %s went to %s", user, buf)
+		}
+	}
+	if welcome.ToUserName != "" || welcome.CreateTime != 0 {
+		t.Fatalf("MarshalReply modified the shared reply: %#v", welcome.ReplyHeader)
+	}
+
+	// 多个推送同时使用同一个回复
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = MarshalReply(&EventMessage{EventHeaderMessage: EventHeaderMessage{FromUserName: "o_c"}}, welcome)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestEventReplyServeWritesReply(t *testing.T) {
+	plaintext := "<xml><ToUserName><![CDATA[gh_1]]></ToUserName><FromUserName><![CDATA[o_user]]></FromUserName>" +
+		"<CreateTime>1600000000</CreateTime><MsgType><![CDATA[text]]></MsgType><Content><![CDATA[hi]]></Content><MsgId>2001</MsgId></xml>"
+	tests := []struct {
+		name      string
+		encrypted bool
+	}{
+		{"plaintext", false},
+		{"safe mode", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer()
+			var r *http.Request
+			if tt.encrypted {
+				r = newEncryptedRequest(t, server, http.MethodPost, "54321", plaintext, false)
+			} else {
+				r = newPlainPush(server, plaintext)
+			}
+			w := httptest.NewRecorder()
+			server.EventReplyServe(w, r, func(message *EventMessage) Reply {
+				return NewTextReply("echo: " + message.Content)
+			})
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", w.Code)
+			}
+			body := w.Body.Bytes()
+			if tt.encrypted {
+				var resp CipherResponseHttpBody
+				err := xml.Unmarshal(body, &resp)
+				if err != nil {
+					t.Fatal(err)
+				}
+				crypter, err := crypto.NewMsgCrypter(server.Token, server.AesKey, server.AppId)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.Nonce != "54321" || !crypter.VerifyMsgSignature(resp.MsgSignature, resp.TimeStamp, resp.Nonce, resp.Encrypt) {
+					t.Fatalf("reply signature does not verify: %#v", resp)
+				}
+				body, err = crypter.Decrypt(resp.Encrypt)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !strings.Contains(string(body), "<Content><![CDATA[echo: hi]]></Content>") ||
+				!strings.Contains(string(body), "<ToUserName><![CDATA[o_user]]></ToUserName>") {
+				t.Fatalf("unexpected reply %s", body)
+			}
+		})
+	}
+}
@@ -2,9 +2,14 @@ package core
 
 import (
 	"encoding/xml"
+	"errors"
+	"github.com/mrwangjinjin/go-wechat/internal/util"
+	"github.com/mrwangjinjin/go-wechat/pkg/crypto"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"sync"
+	"time"
 )
 
 const (
@@ -25,6 +30,7 @@ const (
 )
 
 type EventHandler func(message *EventMessage)
+type EventReplyHandler func(message *EventMessage) Reply
 type EventNotifyHandler func(message *NotifyMessage)
 
 type Server struct {
@@ -33,91 +39,148 @@ type Server struct {
 	AppSecret string
 	Token     string
 	AesKey    string
+	// ReplayWindow 推送 timestamp 的有效范围, 超出范围时返回403, 范围内重复的 nonce 回复 success 不再处理,
+	// 为0时使用 DefaultReplayWindow, 小于0时不校验
+	ReplayWindow time.Duration
+	// AuthorizerRoute 从请求中取授权方 appid, 如 PathTemplateRoute("/event/{appid}"), 为空时按 ToUserName 查找
+	AuthorizerRoute func(r *http.Request) string
+
+	notify        notifyRouter
+	errorHandlers []ErrorHandler
+	async         *asyncPool
+
+	authorizerMu    sync.RWMutex
+	authorizerNames map[string]string
 }
 
 func NewServer(clientConfig *ClientConfig, cache Cache) *Server {
 	return &Server{
-		Cache:     cache,
+		Cache:     WithNamespace(cache, clientConfig.Namespace),
 		AppId:     clientConfig.AppId,
 		AppSecret: clientConfig.AppSecret,
 		Token:     clientConfig.Token,
 		AesKey:    clientConfig.AesKey,
+
+		ReplayWindow: clientConfig.ReplayWindow,
 	}
 }
 
-// Serve 处理事件推送
+// Serve 处理事件推送, 按 InfoType 调用 OnAuthorized 等注册的处理函数,
+// eventHandler 可为空, 不为空时处理未注册的推送类型.
+// 签名校验失败返回403, 解密或解析失败返回400, 错误通过 OnError 注册的函数通知
 func (self *Server) Serve(w http.ResponseWriter, r *http.Request, eventHandler EventNotifyHandler) {
-	encryptType := r.URL.Query().Get("encrypt_type")
-	if encryptType == "" {
+	if isVerifyURLRequest(r) {
+		self.VerifyURL(w, r)
+		return
+	}
+	req, ok := self.decodePush(w, r)
+	if !ok {
+		return
+	}
+	self.serveNotify(w, r, req, eventHandler)
+}
+
+// serveNotify 处理解密后的第三方平台推送
+func (self *Server) serveNotify(w http.ResponseWriter, r *http.Request, req *pushRequest, eventHandler EventNotifyHandler) {
+	var notifyMsg NotifyMessage
+	err := xml.Unmarshal(req.plaintext, &notifyMsg)
+	if err != nil {
+		self.fail(w, r, newServeError(ErrUnknownMessage, err))
+		return
+	}
+
+	log.Println(notifyMsg)
+
+	// 处理推送事件
+	if self.async != nil {
+		ok := self.dispatchAsync(w, r, func() {
+			self.dispatchNotify(&notifyMsg, eventHandler)
+		})
+		if !ok {
+			// 未处理的推送需要微信重试
+			self.forgetNonce(req.timestamp, req.nonce)
+		}
+		return
+	}
+	err = callSafely(func() {
+		self.dispatchNotify(&notifyMsg, eventHandler)
+	})
+	if err != nil {
+		// 处理失败的推送需要微信重试
+		self.forgetNonce(req.timestamp, req.nonce)
+		self.fail(w, r, err)
 		return
 	}
-	switch encryptType {
+	writeSuccess(w)
+}
+
+// pushRequest 校验签名并解密后的推送
+type pushRequest struct {
+	plaintext []byte
+	timestamp string
+	nonce     string
+	encrypted bool
+}
+
+// decodePush 校验签名并解密推送, 失败时返回错误状态码,
+// 已处理过的 nonce 为微信在处理完成前或未收到响应时的重试, 回复 success 不再处理
+func (self *Server) decodePush(w http.ResponseWriter, r *http.Request) (*pushRequest, bool) {
+	req, err := self.decodeRequest(r)
+	if errors.Is(err, errRetried) {
+		writeSuccess(w)
+		return nil, false
+	}
+	if err != nil {
+		self.fail(w, r, err)
+		return nil, false
+	}
+	return req, true
+}
+
+// decodeRequest 校验签名并解密推送, encrypt_type 为空或 raw 时为明文模式
+func (self *Server) decodeRequest(r *http.Request) (*pushRequest, error) {
+	query := r.URL.Query()
+	req := &pushRequest{
+		timestamp: query.Get("timestamp"),
+		nonce:     query.Get("nonce"),
+	}
+	signature := query.Get("signature")
+	switch query.Get("encrypt_type") {
+	case "", "raw":
+		// 验证签名
+		if signature == "" || !util.SecureCompareString(signature, util.Sign(self.Token, req.timestamp, req.nonce)) {
+			return nil, newServeError(ErrBadSignature, nil)
+		}
+		err := self.checkReplay(req.timestamp, req.nonce)
+		if err != nil {
+			return nil, err
+		}
+		req.plaintext = self.ReadXML(r)
 	default:
-		fallthrough
-	case "aes":
 		decoder := MessageDecoder{
-			Signature:    r.URL.Query().Get("signature"),
-			Timestamp:    r.URL.Query().Get("timestamp"),
-			Nonce:        r.URL.Query().Get("nonce"),
-			MsgSignature: r.URL.Query().Get("msg_signature"),
+			Signature:    signature,
+			Timestamp:    req.timestamp,
+			Nonce:        req.nonce,
+			MsgSignature: query.Get("msg_signature"),
 			EncryptMsg:   self.ReadXML(r),
 		}
 		// 验证签名
 		if !decoder.VerifySignature(self.Token) {
-			return
+			return nil, newServeError(ErrBadSignature, nil)
 		}
-		// 解密消息
-		decryptMsg, err := decoder.DecodeComponentVerifyTicket(self.AppId, self.AesKey)
+		err := self.checkReplay(req.timestamp, req.nonce)
 		if err != nil {
-			return
-		}
-
-		log.Println(decryptMsg)
-
-		// 处理推送事件
-		switch decryptMsg.InfoType {
-		case EventComponentVerifyTicket:
-			if !self.Cache.Exists(ComponentTicketCacheKeyPrefix + self.AppId) {
-				_ = self.Cache.SetEx(ComponentTicketCacheKeyPrefix+self.AppId, map[string]interface{}{
-					"component_verify_ticket": decryptMsg.ComponentVerifyTicket,
-				}, 3600*10)
-			}
-			w.WriteHeader(http.StatusOK)
-			_, _ = w.Write([]byte("success"))
-			break
-		case EventAuthorized:
-			w.WriteHeader(http.StatusOK)
-			_, _ = w.Write([]byte("success"))
-			break
-		case EventUpdateAuthorized:
-			w.WriteHeader(http.StatusOK)
-			_, _ = w.Write([]byte("success"))
-			break
-		case EventUnauthorized:
-			w.WriteHeader(http.StatusOK)
-			_, _ = w.Write([]byte("success"))
-			break
-		case EventNotifyThirdFasteregister:
-			w.WriteHeader(http.StatusOK)
-			_, _ = w.Write([]byte("success"))
-			break
-		default:
-			eventHandler(&decryptMsg)
-			break
+			return nil, err
 		}
-	case "raw":
-		var eventMsg NotifyMessage
-		err := xml.Unmarshal(self.ReadXML(r), &eventMsg)
+		// 解密消息
+		plaintext, err := decoder.Decrypt(self.AppId, self.AesKey)
 		if err != nil {
-			return
+			return nil, err
 		}
-
-		log.Println(eventMsg)
-
-		eventHandler(&eventMsg)
-		return
+		req.plaintext = plaintext
+		req.encrypted = true
 	}
-	return
+	return req, nil
 }
 
 func (self *Server) ReadXML(r *http.Request) []byte {
@@ -131,47 +194,172 @@ func (self *Server) ReadXML(r *http.Request) []byte {
 	return body
 }
 
+// EventServe 处理授权方的消息与事件推送, 回复 success, eventHandler 为空时使用 OnEvent 注册的处理函数
 func (self *Server) EventServe(w http.ResponseWriter, r *http.Request, eventHandler EventHandler) {
+	if eventHandler == nil {
+		self.EventReplyServe(w, r, nil)
+		return
+	}
+	self.EventReplyServe(w, r, func(message *EventMessage) Reply {
+		eventHandler(message)
+		return nil
+	})
+}
+
+// EventReplyServe 处理授权方的消息与事件推送, eventHandler 返回的消息作为被动回复,
+// 返回 nil 时回复 success, 安全模式下回复会加密后写入. 重试的推送不会重复调用 eventHandler
+func (self *Server) EventReplyServe(w http.ResponseWriter, r *http.Request, eventHandler EventReplyHandler) {
 	log.Println(r.URL.String())
-	encryptType := r.URL.Query().Get("encrypt_type")
-	if encryptType == "" {
+	if isVerifyURLRequest(r) {
+		self.VerifyURL(w, r)
 		return
 	}
-	switch encryptType {
-	default:
-		fallthrough
-	case "aes":
-		decoder := MessageDecoder{
-			Signature:    r.URL.Query().Get("signature"),
-			Timestamp:    r.URL.Query().Get("timestamp"),
-			Nonce:        r.URL.Query().Get("nonce"),
-			MsgSignature: r.URL.Query().Get("msg_signature"),
-			EncryptMsg:   self.ReadXML(r),
+	req, ok := self.decodePush(w, r)
+	if !ok {
+		return
+	}
+	if eventHandler == nil {
+		eventHandler = self.handleEvent
+	}
+	self.serveEvent(w, r, req, eventHandler)
+}
+
+// serveEvent 处理解密后的授权方消息与事件推送
+func (self *Server) serveEvent(w http.ResponseWriter, r *http.Request, req *pushRequest, eventHandler EventReplyHandler) {
+	eventMsg, err := ParseEventMessage(req.plaintext)
+	if err != nil {
+		self.fail(w, r, newServeError(ErrUnknownMessage, err))
+		return
+	}
+	eventMsg.AuthorizerAppId = self.resolveAuthorizer(r, &eventMsg)
+	log.Println(eventMsg)
+
+	// 微信重试的推送直接回复 success
+	if self.isDuplicate(&eventMsg) {
+		writeSuccess(w)
+		return
+	}
+
+	// 全网发布检测
+	if reply, ok := self.autoTest(&eventMsg); ok {
+		if reply == nil {
+			w.WriteHeader(http.StatusOK)
+			return
 		}
-		// 验证签名
-		if !decoder.VerifySignature(self.Token) {
+		self.writeReply(w, &eventMsg, reply, req.nonce, req.encrypted)
+		return
+	}
+
+	if self.async != nil {
+		ok := self.dispatchAsync(w, r, func() {
+			if eventHandler(&eventMsg) != nil {
+				log.Println("异步模式不支持被动回复, 回复已忽略")
+			}
+		})
+		if !ok {
+			// 未处理的推送需要微信重试
+			self.forgetNonce(req.timestamp, req.nonce)
+			self.forgetMessage(&eventMsg)
+		}
+		return
+	}
+
+	var reply Reply
+	err = callSafely(func() {
+		reply = eventHandler(&eventMsg)
+	})
+	if err != nil {
+		// 处理失败的推送需要微信重试, 重试时不能被去重
+		self.forgetNonce(req.timestamp, req.nonce)
+		self.forgetMessage(&eventMsg)
+		self.fail(w, r, err)
+		return
+	}
+	self.writeReply(w, &eventMsg, reply, req.nonce, req.encrypted)
+}
+
+// VerifyURL 响应配置服务器地址时的 echostr 校验请求, 明文模式校验 signature 后原样返回 echostr,
+// 安全与兼容模式带 msg_signature 时校验后返回解密的 echostr
+func (self *Server) VerifyURL(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	timestamp := query.Get("timestamp")
+	nonce := query.Get("nonce")
+	echoStr := query.Get("echostr")
+
+	msgSignature := query.Get("msg_signature")
+	if msgSignature == "" {
+		// 明文模式不需要 AesKey
+		crypter := &crypto.MsgCrypter{Token: self.Token}
+		if !crypter.VerifySignature(query.Get("signature"), timestamp, nonce) {
+			self.fail(w, r, newServeError(ErrBadSignature, nil))
 			return
 		}
-		// 解密消息
-		decryptMsg, err := decoder.DecodeEventMessage(self.AppId, self.AesKey)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(echoStr))
+		return
+	}
+
+	crypter, err := crypto.NewMsgCrypter(self.Token, self.AesKey, self.AppId)
+	if err != nil {
+		self.fail(w, r, newServeError(ErrInvalidConfig, err))
+		return
+	}
+	plaintext, err := crypter.VerifyURL(msgSignature, timestamp, nonce, echoStr)
+	if errors.Is(err, crypto.ErrInvalidSignature) {
+		self.fail(w, r, newServeError(ErrBadSignature, nil))
+		return
+	}
+	if err != nil {
+		self.fail(w, r, newServeError(ErrDecrypt, err))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(plaintext)
+}
+
+// isVerifyURLRequest GET 请求且带 echostr 时为服务器地址校验
+func isVerifyURLRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet && r.URL.Query().Get("echostr") != ""
+}
+
+// writeReply 写入被动回复, encrypted 为 true 时加密并签名后以 CipherResponseHttpBody 写入
+func (self *Server) writeReply(w http.ResponseWriter, message *EventMessage, reply Reply, nonce string, encrypted bool) {
+	if reply == nil {
+		writeSuccess(w)
+		return
+	}
+	buf, err := MarshalReply(message, reply)
+	if err != nil {
+		log.Println(err)
+		writeSuccess(w)
+		return
+	}
+	if encrypted {
+		encoder := MessageEncoder{
+			Nonce:  nonce,
+			RawMsg: buf,
+		}
+		body, err := encoder.EncryptMessage(self.AppId, self.Token, self.AesKey)
 		if err != nil {
+			log.Println(err)
+			writeSuccess(w)
 			return
 		}
-		log.Println(decryptMsg)
-
-		eventHandler(&decryptMsg)
-	case "raw":
-		var eventMsg EventMessage
-		err := xml.Unmarshal(self.ReadXML(r), &eventMsg)
+		buf, err = xml.Marshal(body)
 		if err != nil {
+			log.Println(err)
+			writeSuccess(w)
 			return
 		}
-		log.Println(eventMsg)
-		eventHandler(&eventMsg)
-		return
 	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf)
 }
 
+// NewTextMessage 序列化文本消息
+//
+// Deprecated: 使用 NewTextReply 并在 EventReplyServe 中返回
 func (self *Server) NewTextMessage(w http.ResponseWriter, text *Text) ([]byte, error) {
 	buf, err := xml.Marshal(text)
 	if err != nil {
@@ -179,3 +367,9 @@ func (self *Server) NewTextMessage(w http.ResponseWriter, text *Text) ([]byte, e
 	}
 	return buf, nil
 }
+
+// writeSuccess 无需回复时返回 success, 微信不会重试
+func writeSuccess(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("success"))
+}
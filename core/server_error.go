@@ -0,0 +1,72 @@
+package core
+
+import (
+	"errors"
+	"log"
+	"net/http"
+)
+
+var (
+	ErrBadSignature   = errors.New("签名校验失败")
+	ErrReplay         = errors.New("过期的请求")
+	ErrDecrypt        = errors.New("消息解密失败")
+	ErrUnknownMessage = errors.New("无法解析的消息")
+	ErrInvalidConfig  = errors.New("Token、AesKey 或 AppId 配置错误")
+)
+
+// ServeError 处理推送失败, Kind 为 ErrBadSignature 等错误类型, 可用 errors.Is 判断
+type ServeError struct {
+	Kind   error
+	Status int   // 返回给微信的HTTP状态码
+	Err    error // 原始错误
+}
+
+func (self *ServeError) Error() string {
+	if self.Err != nil {
+		return self.Kind.Error() + ": " + self.Err.Error()
+	}
+	return self.Kind.Error()
+}
+
+func (self *ServeError) Unwrap() error {
+	return self.Err
+}
+
+func (self *ServeError) Is(target error) bool {
+	return self.Kind == target
+}
+
+func newServeError(kind error, err error) *ServeError {
+	status := http.StatusBadRequest
+	switch kind {
+	case ErrBadSignature, ErrReplay:
+		status = http.StatusForbidden
+	case ErrInvalidConfig:
+		status = http.StatusInternalServerError
+	}
+	return &ServeError{
+		Kind:   kind,
+		Status: status,
+		Err:    err,
+	}
+}
+
+type ErrorHandler func(r *http.Request, err error)
+
+// OnError 推送处理失败, err 为 *ServeError
+func (self *Server) OnError(handler ErrorHandler) {
+	self.errorHandlers = append(self.errorHandlers, handler)
+}
+
+// fail 调用错误处理函数并返回错误状态码
+func (self *Server) fail(w http.ResponseWriter, r *http.Request, err error) {
+	var serveErr *ServeError
+	if !errors.As(err, &serveErr) {
+		serveErr = newServeError(ErrUnknownMessage, err)
+	}
+	log.Println(serveErr)
+	for _, handler := range self.errorHandlers {
+		handler(r, serveErr)
+	}
+	http.Error(w, http.StatusText(serveErr.Status), serveErr.Status)
+}
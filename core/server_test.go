@@ -0,0 +1,219 @@
+package core
+
+import (
+	"errors"
+	"github.com/mrwangjinjin/go-wechat/internal/util"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// newPlainEcho 构造明文模式的服务器地址校验请求
+func newPlainEcho(token, echoStr string) *http.Request {
+	query := url.Values{
+		"timestamp": {"1600000000"},
+		"nonce":     {"12345"},
+		"signature": {util.Sign(token, "1600000000", "12345")},
+		"echostr":   {echoStr},
+	}
+	return httptest.NewRequest(http.MethodGet, "/?"+query.Encode(), nil)
+}
+
+func TestVerifyURL(t *testing.T) {
+	serves := []struct {
+		name  string
+		serve func(server *Server, w http.ResponseWriter, r *http.Request)
+	}{
+		{"Serve", func(server *Server, w http.ResponseWriter, r *http.Request) {
+			server.Serve(w, r, nil)
+		}},
+		{"EventServe", func(server *Server, w http.ResponseWriter, r *http.Request) {
+			server.EventServe(w, r, nil)
+		}},
+	}
+	tests := []struct {
+		name    string
+		request func(t *testing.T, server *Server) *http.Request
+		aesKey  string // 不为空时在请求构造后替换 Server 的 AesKey
+		status  int
+		body    string
+		kind    error
+	}{
+		{
+			name: "plaintext",
+			request: func(t *testing.T, server *Server) *http.Request {
+				return newPlainEcho(server.Token, "echo_plain")
+			},
+			status: http.StatusOK,
+			body:   "echo_plain",
+		},
+		{
+			name: "plaintext bad signature",
+			request: func(t *testing.T, server *Server) *http.Request {
+				return newPlainEcho("other_token", "echo_plain")
+			},
+			status: http.StatusForbidden,
+			kind:   ErrBadSignature,
+		},
+		{
+			name: "safe mode",
+			request: func(t *testing.T, server *Server) *http.Request {
+				return newEncryptedRequest(t, server, http.MethodGet, "12345", "echo_safe", true)
+			},
+			status: http.StatusOK,
+			body:   "echo_safe",
+		},
+		{
+			name: "safe mode bad signature",
+			request: func(t *testing.T, server *Server) *http.Request {
+				r := newEncryptedRequest(t, server, http.MethodGet, "12345", "echo_safe", true)
+				query := r.URL.Query()
+				query.Set("msg_signature", "bad")
+				r.URL.RawQuery = query.Encode()
+				return r
+			},
+			status: http.StatusForbidden,
+			kind:   ErrBadSignature,
+		},
+		{
+			name: "safe mode other aes key",
+			request: func(t *testing.T, server *Server) *http.Request {
+				return newEncryptedRequest(t, server, http.MethodGet, "12345", "echo_safe", true)
+			},
+			aesKey: "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789abcdefg",
+			status: http.StatusBadRequest,
+			kind:   ErrDecrypt,
+		},
+		{
+			name: "safe mode invalid aes key",
+			request: func(t *testing.T, server *Server) *http.Request {
+				return newEncryptedRequest(t, server, http.MethodGet, "12345", "echo_safe", true)
+			},
+			aesKey: "short",
+			status: http.StatusInternalServerError,
+			kind:   ErrInvalidConfig,
+		},
+	}
+	for _, serve := range serves {
+		for _, tt := range tests {
+			t.Run(serve.name+"/"+tt.name, func(t *testing.T) {
+				server := newTestServer()
+				r := tt.request(t, server)
+				if tt.aesKey != "" {
+					server.AesKey = tt.aesKey
+				}
+				var served error
+				server.OnError(func(r *http.Request, err error) {
+					served = err
+				})
+				w := httptest.NewRecorder()
+				serve.serve(server, w, r)
+				if w.Code != tt.status {
+					t.Fatalf("expected %d, got %d", tt.status, w.Code)
+				}
+				if tt.body != "" && w.Body.String() != tt.body {
+					t.Fatalf("expected %q, got %q", tt.body, w.Body.String())
+				}
+				if tt.kind != nil && !errors.Is(served, tt.kind) {
+					t.Fatalf("expected %v, got %v", tt.kind, served)
+				}
+			})
+		}
+	}
+}
+
+func TestServeErrorStatus(t *testing.T) {
+	serves := []struct {
+		name  string
+		serve func(server *Server, w http.ResponseWriter, r *http.Request)
+	}{
+		{"Serve", func(server *Server, w http.ResponseWriter, r *http.Request) {
+			server.Serve(w, r, nil)
+		}},
+		{"EventServe", func(server *Server, w http.ResponseWriter, r *http.Request) {
+			server.EventServe(w, r, nil)
+		}},
+	}
+	tests := []struct {
+		name    string
+		request func(t *testing.T, server *Server) *http.Request
+		status  int
+		kind    error
+	}{
+		{
+			name: "bad signature",
+			request: func(t *testing.T, server *Server) *http.Request {
+				r := newPlainPush(server, "<xml></xml>")
+				query := r.URL.Query()
+				query.Set("signature", "bad")
+				r.URL.RawQuery = query.Encode()
+				return r
+			},
+			status: http.StatusForbidden,
+			kind:   ErrBadSignature,
+		},
+		{
+			name: "missing signature",
+			request: func(t *testing.T, server *Server) *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/?timestamp=1600000000&nonce=1", nil)
+			},
+			status: http.StatusForbidden,
+			kind:   ErrBadSignature,
+		},
+		{
+			name: "stale timestamp",
+			request: func(t *testing.T, server *Server) *http.Request {
+				query := url.Values{
+					"timestamp": {"1600000000"},
+					"nonce":     {"12345"},
+					"signature": {util.Sign(server.Token, "1600000000", "12345")},
+				}
+				return httptest.NewRequest(http.MethodPost, "/?"+query.Encode(), nil)
+			},
+			status: http.StatusForbidden,
+			kind:   ErrReplay,
+		},
+		{
+			name: "unparsable message",
+			request: func(t *testing.T, server *Server) *http.Request {
+				return newPlainPush(server, "<xml><ToUserName>")
+			},
+			status: http.StatusBadRequest,
+			kind:   ErrUnknownMessage,
+		},
+		{
+			name: "undecryptable message",
+			request: func(t *testing.T, server *Server) *http.Request {
+				other := newTestServer()
+				other.AesKey = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789abcdefg"
+				return newEncryptedRequest(t, other, http.MethodPost, "12345", "<xml></xml>", false)
+			},
+			status: http.StatusBadRequest,
+			kind:   ErrDecrypt,
+		},
+	}
+	for _, serve := range serves {
+		for _, tt := range tests {
+			t.Run(serve.name+"/"+tt.name, func(t *testing.T) {
+				server := newTestServer()
+				var served []error
+				server.OnError(func(r *http.Request, err error) {
+					served = append(served, err)
+				})
+				w := httptest.NewRecorder()
+				serve.serve(server, w, tt.request(t, server))
+				if w.Code != tt.status {
+					t.Fatalf("expected %d, got %d", tt.status, w.Code)
+				}
+				if len(served) != 1 || !errors.Is(served[0], tt.kind) {
+					t.Fatalf("expected one %v, got %v", tt.kind, served)
+				}
+				var serveErr *ServeError
+				if !errors.As(served[0], &serveErr) || serveErr.Status != tt.status {
+					t.Fatalf("OnError got %#v", served[0])
+				}
+			})
+		}
+	}
+}
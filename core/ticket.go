@@ -0,0 +1,67 @@
+package core
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// ComponentTicketExpires component_verify_ticket 有效期12小时, 微信每10分钟推送一次
+const ComponentTicketExpires = 3600 * 12
+
+// ComponentTicket 缓存的 component_verify_ticket, 保留上一次推送的票据用于新票据被拒绝时重试
+type ComponentTicket struct {
+	ComponentVerifyTicket string `json:"component_verify_ticket"`
+	ReceivedAt            int64  `json:"received_at"`
+	PreviousTicket        string `json:"previous_ticket,omitempty"`
+	PreviousReceivedAt    int64  `json:"previous_received_at,omitempty"`
+}
+
+// Age 距离收到票据的时间
+func (self *ComponentTicket) Age() time.Duration {
+	if self.ReceivedAt == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(self.ReceivedAt, 0))
+}
+
+// LoadComponentTicket 读取第三方平台缓存的 component_verify_ticket
+func LoadComponentTicket(cache Cache, appId string) (*ComponentTicket, error) {
+	val, err := cache.Get(ComponentTicketCacheKeyPrefix + appId)
+	if err != nil {
+		return nil, err
+	}
+	var ticket ComponentTicket
+	err = json.Unmarshal([]byte(val), &ticket)
+	if err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// ComponentTicket 读取缓存的 component_verify_ticket
+func (self *Server) ComponentTicket() (*ComponentTicket, error) {
+	return LoadComponentTicket(self.Cache, self.AppId)
+}
+
+// saveComponentTicket 缓存每次推送的 component_verify_ticket, 票据变化时保留上一个票据
+func (self *Server) saveComponentTicket(msg *NotifyMessage) {
+	ticket := &ComponentTicket{
+		ComponentVerifyTicket: msg.ComponentVerifyTicket,
+		ReceivedAt:            time.Now().Unix(),
+	}
+	current, err := self.ComponentTicket()
+	if err == nil {
+		if current.ComponentVerifyTicket != msg.ComponentVerifyTicket {
+			ticket.PreviousTicket = current.ComponentVerifyTicket
+			ticket.PreviousReceivedAt = current.ReceivedAt
+		} else {
+			ticket.PreviousTicket = current.PreviousTicket
+			ticket.PreviousReceivedAt = current.PreviousReceivedAt
+		}
+	}
+	err = self.Cache.SetEx(ComponentTicketCacheKeyPrefix+self.AppId, ticket, ComponentTicketExpires)
+	if err != nil {
+		log.Println(err)
+	}
+}
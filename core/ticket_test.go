@@ -0,0 +1,41 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestSaveComponentTicketKeepsPrevious(t *testing.T) {
+	tests := []struct {
+		name     string
+		pushes   []string
+		current  string
+		previous string
+	}{
+		{"first push", []string{"t1"}, "t1", ""},
+		{"new ticket", []string{"t1", "t2"}, "t2", "t1"},
+		{"same ticket pushed again", []string{"t1", "t2", "t2"}, "t2", "t1"},
+		{"rotated twice", []string{"t1", "t2", "t3"}, "t3", "t2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer()
+			for _, ticket := range tt.pushes {
+				server.saveComponentTicket(&NotifyMessage{ComponentVerifyTicket: ticket})
+			}
+			ticket, err := server.ComponentTicket()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ticket.ComponentVerifyTicket != tt.current || ticket.PreviousTicket != tt.previous {
+				t.Fatalf("expected %s/%s, got %s/%s", tt.current, tt.previous, ticket.ComponentVerifyTicket, ticket.PreviousTicket)
+			}
+			if ticket.ReceivedAt == 0 || (tt.previous != "" && ticket.PreviousReceivedAt == 0) {
+				t.Fatalf("receive time not recorded: %#v", ticket)
+			}
+			ttl, err := server.Cache.TTL(ComponentTicketCacheKeyPrefix + server.AppId)
+			if err != nil || ttl <= 0 || ttl > ComponentTicketExpires {
+				t.Fatalf("unexpected ticket ttl %d, err %v", ttl, err)
+			}
+		})
+	}
+}
@@ -4,17 +4,11 @@ import (
 	"github.com/mrwangjinjin/go-wechat/core"
 	"log"
 	"net/http"
-	"time"
 )
 
 func main() {
-	cache := core.NewCache(&core.CacheConfig{
-		MaxIdle:     30,
-		MaxActive:   30,
-		IdleTimeout: time.Duration(time.Second * 200),
-		Host:        "127.0.0.1:6379",
-		Auth:        "",
-	})
+	// 多实例部署时使用 core.NewCache 或 core.NewClusterCache
+	cache := core.NewMemoryCache()
 	config := &core.ClientConfig{
 		AppId:     "",
 		AppSecret: "",
@@ -23,12 +17,17 @@ func main() {
 		BaseUrl:   "https://api.weixin.qq.com",
 	}
 	server := core.NewServer(config, cache)
-	http.HandleFunc("/api/notify", func(w http.ResponseWriter, r *http.Request) {
-		server.Serve(w, r)
+	server.OnAuthorized(func(event *core.AuthorizedEvent) {
+		log.Println("authorized:", event.AuthorizerAppid)
 	})
-	http.HandleFunc("/api/event", func(w http.ResponseWriter, r *http.Request) {
-		server.EventServe(w, r)
+	server.OnEvent(func(message *core.EventMessage) core.Reply {
+		if text, ok := message.Payload.(*core.TextMessage); ok {
+			return core.NewTextReply(text.Content)
+		}
+		return nil
 	})
+	http.Handle("/api/notify", server.HandleNotify())
+	http.Handle("/api/event", server.HandleEvent())
 	log.Println("Server listen at 127.0.0.1:9595")
 	err := http.ListenAndServe("127.0.0.1:9595", nil)
 	if err != nil {
@@ -3,7 +3,6 @@ module github.com/mrwangjinjin/go-wechat
 go 1.13
 
 require (
-	github.com/conetse/WXBizMsgCrypt v0.0.0-20180416085802-b5a6c8e8b043
 	github.com/gomodule/redigo v2.0.0+incompatible
 	github.com/stretchr/testify v1.4.0 // indirect
 	github.com/tidwall/gjson v1.3.2
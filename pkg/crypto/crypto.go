@@ -0,0 +1,92 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/mrwangjinjin/go-wechat/internal/util"
+)
+
+var (
+	ErrInvalidAesKey    = errors.New("EncodingAESKey 长度必须为43位")
+	ErrInvalidSignature = errors.New("签名校验失败")
+)
+
+// MsgCrypter 微信消息加解密与签名, 兼容官方 WXBizMsgCrypt 的安全模式
+type MsgCrypter struct {
+	Token  string
+	AesKey string
+	AppId  string
+}
+
+func NewMsgCrypter(token, aesKey, appId string) (*MsgCrypter, error) {
+	if len(aesKey) != 43 {
+		return nil, ErrInvalidAesKey
+	}
+	key, err := base64.StdEncoding.DecodeString(aesKey + "=")
+	if err != nil || len(key) != 32 {
+		return nil, ErrInvalidAesKey
+	}
+	return &MsgCrypter{
+		Token:  token,
+		AesKey: aesKey,
+		AppId:  appId,
+	}, nil
+}
+
+// Encrypt 加密明文消息, 返回 base64 编码的密文
+func (self *MsgCrypter) Encrypt(rawMsg []byte) (string, error) {
+	random := make([]byte, 16)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", err
+	}
+	return self.EncryptWithRandom(random, rawMsg)
+}
+
+// EncryptWithRandom 使用指定的16字节随机串加密, 用于生成可复现的密文
+func (self *MsgCrypter) EncryptWithRandom(random, rawMsg []byte) (string, error) {
+	encryptMsg, err := util.EncryptMsg(random, rawMsg, self.AppId, self.AesKey)
+	if err != nil {
+		return "", err
+	}
+	return string(encryptMsg), nil
+}
+
+// Decrypt 解密 base64 编码的密文并校验 appid
+func (self *MsgCrypter) Decrypt(encryptMsg string) ([]byte, error) {
+	_, rawMsg, err := util.DecryptMsg(self.AppId, self.AesKey, encryptMsg)
+	if err != nil {
+		return nil, err
+	}
+	return rawMsg, nil
+}
+
+// Sign 安全模式的消息签名 msg_signature
+func (self *MsgCrypter) Sign(timestamp, nonce, encryptMsg string) string {
+	return util.MsgSign(self.Token, timestamp, nonce, encryptMsg)
+}
+
+// VerifySignature 校验明文模式的 signature
+func (self *MsgCrypter) VerifySignature(signature, timestamp, nonce string) bool {
+	if signature == "" {
+		return false
+	}
+	return util.SecureCompareString(signature, util.Sign(self.Token, timestamp, nonce))
+}
+
+// VerifyMsgSignature 校验安全模式的 msg_signature
+func (self *MsgCrypter) VerifyMsgSignature(msgSignature, timestamp, nonce, encryptMsg string) bool {
+	if msgSignature == "" {
+		return false
+	}
+	return util.SecureCompareString(msgSignature, self.Sign(timestamp, nonce, encryptMsg))
+}
+
+// VerifyURL 校验服务器配置时的 msg_signature 并解密 echostr
+func (self *MsgCrypter) VerifyURL(msgSignature, timestamp, nonce, echoStr string) ([]byte, error) {
+	if !self.VerifyMsgSignature(msgSignature, timestamp, nonce, echoStr) {
+		return nil, ErrInvalidSignature
+	}
+	return self.Decrypt(echoStr)
+}
@@ -0,0 +1,196 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// 官方 WXBizMsgCrypt 示例中的参数与密文
+const (
+	sampleToken        = "spamtest"
+	sampleAesKey       = "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"
+	sampleAppId        = "wx2c2769f8efd9abc2"
+	sampleTimestamp    = "1409735669"
+	sampleNonce        = "1320562132"
+	sampleMsgSignature = "5d197aaffba7e9b25a30732f161a50dee96bd5fa"
+	sampleEncrypt      = "hyzAe4OzmOMbd6TvGdIOO6uBmdJoD0Fk53REIHvxYtJlE2B655HuD0m8KUePWB3+LrPXo87wzQ1QLvbeUgmBM4x6F8PGHQHFVAFmOD2LdJF9FrXpbUAh0B5GIItb52sn896wVsMSHGuPE328HnRGBcrS7C41IzDWyWNlZkyyXwon8T332jisa+h6tEDYsVticbSnyU8dKOIbgU6ux5VTjg3yt+WGzjlpKn6NPhRjpA912xMezR4kw6KWwMrCVKSVCZciVGCgavjIQ6X8tCOp3yZbGpy0VxpAe+77TszTfRd5RJSVO/HTnifJpXgCSUdUue1v6h0EIBYYI1BD1DlD+C0CR8e6OewpusjZ4uBl9FyJvnhvQl+q5rv1ixrcpCumEPo5MJSgM9ehVsNPfUM669WuMyVWQLCzpu9GhglF2PE="
+	samplePlaintext    = "<xml><ToUserName><![CDATA[gh_10f6c3c3ac5a]]></ToUserName>\n<FromUserName><![CDATA[oyORnuP8q7ou2gfYjqLzSIWZf0rs]]></FromUserName>\n<CreateTime>1409735668</CreateTime>\n<MsgType><![CDATA[text]]></MsgType>\n<Content><![CDATA[abcdteT]]></Content>\n<MsgId>6054768590064713728</MsgId>\n</xml>"
+)
+
+func newSampleCrypter(t *testing.T) *MsgCrypter {
+	crypter, err := NewMsgCrypter(sampleToken, sampleAesKey, sampleAppId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crypter
+}
+
+func TestNewMsgCrypter(t *testing.T) {
+	tests := []struct {
+		name   string
+		aesKey string
+		err    error
+	}{
+		{"sample", sampleAesKey, nil},
+		{"too short", sampleAesKey[:42], ErrInvalidAesKey},
+		{"too long", sampleAesKey + "A", ErrInvalidAesKey},
+		{"not base64", "!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!", ErrInvalidAesKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMsgCrypter(sampleToken, tt.aesKey, sampleAppId)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestSignSample(t *testing.T) {
+	crypter := newSampleCrypter(t)
+	if got := crypter.Sign(sampleTimestamp, sampleNonce, sampleEncrypt); got != sampleMsgSignature {
+		t.Fatalf("expected %s, got %s", sampleMsgSignature, got)
+	}
+}
+
+func TestVerifyMsgSignature(t *testing.T) {
+	crypter := newSampleCrypter(t)
+	tests := []struct {
+		name         string
+		msgSignature string
+		timestamp    string
+		nonce        string
+		encrypt      string
+		ok           bool
+	}{
+		{"sample", sampleMsgSignature, sampleTimestamp, sampleNonce, sampleEncrypt, true},
+		{"empty signature", "", sampleTimestamp, sampleNonce, sampleEncrypt, false},
+		{"wrong timestamp", sampleMsgSignature, "1409735670", sampleNonce, sampleEncrypt, false},
+		{"wrong nonce", sampleMsgSignature, sampleTimestamp, "1320562133", sampleEncrypt, false},
+		{"tampered ciphertext", sampleMsgSignature, sampleTimestamp, sampleNonce, "A" + sampleEncrypt[1:], false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := crypter.VerifyMsgSignature(tt.msgSignature, tt.timestamp, tt.nonce, tt.encrypt); got != tt.ok {
+				t.Fatalf("expected %v, got %v", tt.ok, got)
+			}
+		})
+	}
+}
+
+func TestDecryptSample(t *testing.T) {
+	tests := []struct {
+		name  string
+		appId string
+		ok    bool
+	}{
+		{"sample appid", sampleAppId, true},
+		{"other appid", "wx0000000000000000", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crypter, err := NewMsgCrypter(sampleToken, sampleAesKey, tt.appId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			plaintext, err := crypter.Decrypt(sampleEncrypt)
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("expected appid mismatch error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(plaintext) != samplePlaintext {
+				t.Fatalf("unexpected plaintext %q", plaintext)
+			}
+		})
+	}
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	crypter := newSampleCrypter(t)
+	tests := []struct {
+		name   string
+		rawMsg []byte
+	}{
+		{"sample", []byte(samplePlaintext)},
+		{"empty", []byte{}},
+		{"block aligned", bytes.Repeat([]byte("a"), 32)},
+		{"utf8", []byte("<xml><Content><![CDATA[你好, 微信]]></Content></xml>")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypt, err := crypter.Encrypt(tt.rawMsg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			plaintext, err := crypter.Decrypt(encrypt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(plaintext, tt.rawMsg) {
+				t.Fatalf("expected %q, got %q", tt.rawMsg, plaintext)
+			}
+			signature := crypter.Sign(sampleTimestamp, sampleNonce, encrypt)
+			if !crypter.VerifyMsgSignature(signature, sampleTimestamp, sampleNonce, encrypt) {
+				t.Fatal("signature of round-tripped message did not verify")
+			}
+		})
+	}
+}
+
+func TestEncryptWithRandomIsDeterministic(t *testing.T) {
+	crypter := newSampleCrypter(t)
+	random := []byte("0123456789abcdef")
+	first, err := crypter.EncryptWithRandom(random, []byte(samplePlaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := crypter.EncryptWithRandom(random, []byte(samplePlaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Fatal("same random produced different ciphertext")
+	}
+}
+
+func TestVerifyURL(t *testing.T) {
+	crypter := newSampleCrypter(t)
+	echoStr, err := crypter.Encrypt([]byte("1616140317555161061"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := crypter.Sign(sampleTimestamp, sampleNonce, echoStr)
+
+	tests := []struct {
+		name         string
+		msgSignature string
+		echoStr      string
+		err          error
+	}{
+		{"valid", signature, echoStr, nil},
+		{"bad signature", sampleMsgSignature, echoStr, ErrInvalidSignature},
+		{"empty signature", "", echoStr, ErrInvalidSignature},
+		{"sample message", sampleMsgSignature, sampleEncrypt, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext, err := crypter.VerifyURL(tt.msgSignature, sampleTimestamp, sampleNonce, tt.echoStr)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+			want, _ := crypter.Decrypt(tt.echoStr)
+			if !bytes.Equal(plaintext, want) {
+				t.Fatalf("expected %q, got %q", want, plaintext)
+			}
+		})
+	}
+}